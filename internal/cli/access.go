@@ -0,0 +1,343 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+// Access report formats.
+const (
+	accessTable = "table"
+	accessCSV   = "csv"
+	accessJSON  = "json"
+)
+
+var accessCmd = &cobra.Command{
+	Use:   "access [path...]",
+	Short: "Report who can decrypt which encrypted files",
+	Long: `Invert the metadata of encrypted files into who can decrypt what. Paths may be files or
+directories (default: the current directory).
+
+Recipients are named after the person of the address book holding the key, else the
+profile holding it. Keys nobody holds are reported by key and highlighted. Only the
+recipients in the file metadata count: whoever holds a kms, gcp_kms, azure_kv or
+hc_vault key has access through that service.
+
+Queries:
+  --who <person>  the files a person (or a key, or 'profile <name>') can decrypt
+  --file <path>   the recipients of one file
+
+Formats:
+  table  recipients with their files (default)
+  csv    a matrix with one row per file and one column per recipient
+  json   recipients with their keys, profiles and files
+
+Examples:
+  sopsy access deploy/prod/
+  sopsy access --who bob
+  sopsy access --file deploy/prod/db.yaml
+  sopsy access --format csv -o access.csv`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		who, _ := cmd.Flags().GetString("who")
+		file, _ := cmd.Flags().GetString("file")
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+
+		switch format {
+		case accessTable, accessCSV, accessJSON:
+		default:
+			return fmt.Errorf("unsupported format: %s (supported: %s, %s, %s)", format, accessTable, accessCSV, accessJSON)
+		}
+		if file != "" {
+			if len(args) > 0 {
+				return fmt.Errorf("--file cannot be combined with paths")
+			}
+			args = []string{file}
+		}
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+
+		results, err := scanPaths(args)
+		if err != nil {
+			return err
+		}
+		report := buildAccessReport(results)
+		if who != "" {
+			report = report.filter(who)
+			if len(report.Recipients) == 0 {
+				return fmt.Errorf("%s is not a recipient of any file", who)
+			}
+		}
+
+		var buf bytes.Buffer
+		switch format {
+		case accessCSV:
+			err = report.writeCSV(&buf)
+		case accessJSON:
+			enc := json.NewEncoder(&buf)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(report.Recipients)
+		default:
+			if output == "" {
+				report.print(os.Stdout, file != "")
+				return nil
+			}
+			report.print(&buf, file != "")
+		}
+		if err != nil {
+			return err
+		}
+
+		if output == "" {
+			_, err = os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		if err := os.WriteFile(output, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", output, err)
+		}
+		fmt.Fprintf(os.Stderr, "✓ Wrote %s\n", output)
+		return nil
+	},
+}
+
+// accessReport lists the recipients of a set of encrypted files.
+type accessReport struct {
+	Files      []string
+	Recipients []*accessRecipient
+}
+
+// accessRecipient is a person, profile or unknown key with the files it can decrypt.
+type accessRecipient struct {
+	// Name is the person, "profile <name>", or the key itself when nobody holds it
+	Name     string          `json:"name"`
+	Person   string          `json:"person,omitempty"`
+	Email    string          `json:"email,omitempty"`
+	Keys     []scanRecipient `json:"keys"`
+	Profiles []string        `json:"profiles,omitempty"`
+	Files    []string        `json:"files"`
+}
+
+// Known reports whether a person or profile holds the recipient.
+func (r *accessRecipient) Known() bool {
+	return r.Person != "" || len(r.Profiles) > 0
+}
+
+// scanPaths scans files and directories for encrypted files. Files given explicitly must
+// be encrypted.
+func scanPaths(paths []string) ([]scanResult, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			if _, err := sopsfile.ReadMetadata(path); err != nil {
+				return nil, fmt.Errorf("%s: %w", path, err)
+			}
+			files = append(files, path)
+			continue
+		}
+		listed, err := listFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, listed...)
+	}
+	return scanFiles(dedupe(files)), nil
+}
+
+func buildAccessReport(results []scanResult) *accessReport {
+	report := &accessReport{}
+	byName := make(map[string]*accessRecipient)
+	for _, r := range results {
+		report.Files = append(report.Files, r.Path)
+		for _, rec := range r.Recipients {
+			name := accessName(rec)
+			a, ok := byName[name]
+			if !ok {
+				a = &accessRecipient{Name: name, Person: rec.Person}
+				if person, err := cfg.GetPerson(rec.Person); err == nil {
+					a.Email = person.Email
+				}
+				byName[name] = a
+				report.Recipients = append(report.Recipients, a)
+			}
+			if !containsKey(a.Keys, rec) {
+				a.Keys = append(a.Keys, scanRecipient{Backend: rec.Backend, ID: rec.ID})
+			}
+			a.Profiles = dedupe(append(a.Profiles, rec.Profiles...))
+			if len(a.Files) == 0 || a.Files[len(a.Files)-1] != r.Path {
+				a.Files = append(a.Files, r.Path)
+			}
+		}
+	}
+
+	// People first, then profiles, then unknown keys
+	rank := func(a *accessRecipient) int {
+		switch {
+		case a.Person != "":
+			return 0
+		case len(a.Profiles) > 0:
+			return 1
+		}
+		return 2
+	}
+	sort.SliceStable(report.Recipients, func(i, j int) bool {
+		a, b := report.Recipients[i], report.Recipients[j]
+		if rank(a) != rank(b) {
+			return rank(a) < rank(b)
+		}
+		return a.Name < b.Name
+	})
+	for _, a := range report.Recipients {
+		sort.Strings(a.Profiles)
+	}
+	return report
+}
+
+// accessName names a recipient after its person, else its profiles, else its key.
+func accessName(rec scanRecipient) string {
+	switch {
+	case rec.Person != "":
+		return rec.Person
+	case len(rec.Profiles) > 0:
+		return "profile " + strings.Join(rec.Profiles, "/")
+	}
+	return rec.ID
+}
+
+func containsKey(keys []scanRecipient, rec scanRecipient) bool {
+	for _, k := range keys {
+		if k.Backend == rec.Backend && k.ID == rec.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// filter keeps the recipient named who, holding the key who, or held by the profile
+// "profile <name>", even when other profiles hold the same keys.
+func (r *accessReport) filter(who string) *accessReport {
+	filtered := &accessReport{}
+	for _, a := range r.Recipients {
+		match := a.Name == who
+		for _, k := range a.Keys {
+			match = match || k.ID == who
+		}
+		for _, p := range a.Profiles {
+			match = match || "profile "+p == who
+		}
+		if match {
+			filtered.Recipients = append(filtered.Recipients, a)
+			filtered.Files = append(filtered.Files, a.Files...)
+		}
+	}
+	filtered.Files = dedupe(filtered.Files)
+	sort.Strings(filtered.Files)
+	return filtered
+}
+
+// print writes the recipients and their files. For a single file, only the recipients
+// are listed.
+func (r *accessReport) print(out io.Writer, single bool) {
+	if len(r.Files) == 0 {
+		_, _ = fmt.Fprintln(out, "No encrypted files found")
+		return
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	if single {
+		_, _ = fmt.Fprintln(w, "RECIPIENT\tBACKEND\tKEY")
+		for _, a := range r.Recipients {
+			name := a.Name
+			if !a.Known() {
+				name = "(unknown)"
+			}
+			for _, k := range a.Keys {
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", name, k.Backend, k.ID)
+			}
+		}
+		_ = w.Flush()
+		return
+	}
+
+	_, _ = fmt.Fprintln(w, "RECIPIENT\tFILES\tPROFILES")
+	for _, a := range r.Recipients {
+		name := a.Name
+		if !a.Known() {
+			name = shortKey(a.Name) + " (unknown)"
+		}
+		profiles := strings.Join(a.Profiles, ",")
+		if profiles == "" {
+			profiles = "-"
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%s\n", name, len(a.Files), profiles)
+	}
+	_ = w.Flush()
+
+	for _, a := range r.Recipients {
+		name := a.Name
+		if !a.Known() {
+			name = a.Name + " (unknown)"
+			// Only highlight on the terminal, not in a report written with --output
+			if out == os.Stdout {
+				name = highlight(name)
+			}
+		} else if a.Email != "" {
+			name += " <" + a.Email + ">"
+		}
+		_, _ = fmt.Fprintf(out, "\n%s\n", name)
+		for _, f := range a.Files {
+			_, _ = fmt.Fprintf(out, "  %s\n", f)
+		}
+	}
+}
+
+// writeCSV writes a matrix of files by recipients, with "x" where a recipient can
+// decrypt a file.
+func (r *accessReport) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	header := []string{"file"}
+	for _, a := range r.Recipients {
+		header = append(header, a.Name)
+	}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, f := range r.Files {
+		row := []string{f}
+		for _, a := range r.Recipients {
+			cell := ""
+			if containsName(a.Files, f) {
+				cell = "x"
+			}
+			row = append(row, cell)
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func init() {
+	accessCmd.Flags().String("who", "", "only report the files this person, key or 'profile <name>' can decrypt")
+	accessCmd.Flags().String("file", "", "only report the recipients of this file")
+	accessCmd.Flags().StringP("format", "f", accessTable, "output format: table, csv, json")
+	accessCmd.Flags().StringP("output", "o", "", "write the report to this file instead of stdout")
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff <a> <b> | diff <file> --rev <rev>",
+	Short: "Show a decrypted, structural diff of encrypted files",
+	Long: `Compare two encrypted files, or a file against a git revision, key by key.
+
+Both sides are decrypted with their resolved profile. Values are masked unless
+--show-values is set. When a side cannot be decrypted, only the key structure is
+compared, since sops stores keys in plaintext.
+
+The exit code is 1 when the files differ, like diff(1).
+
+Examples:
+  sopsy diff prod.yaml stg.yaml
+  sopsy diff secrets.yaml --rev HEAD~1
+  sopsy diff secrets.yaml --rev main --show-values`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rev, _ := cmd.Flags().GetString("rev")
+		showValues, _ := cmd.Flags().GetBool("show-values")
+		keysOnly, _ := cmd.Flags().GetBool("keys-only")
+
+		var oldPath, newPath, oldLabel string
+		switch {
+		case rev != "" && len(args) == 1:
+			tmp, err := gitShowToTemp(rev, args[0])
+			if err != nil {
+				return err
+			}
+			defer func() { _ = os.Remove(tmp) }()
+			oldPath, newPath, oldLabel = tmp, args[0], rev+":"+args[0]
+		case rev == "" && len(args) == 2:
+			oldPath, newPath, oldLabel = args[0], args[1], args[0]
+		default:
+			return fmt.Errorf("usage: sopsy diff <a> <b> or sopsy diff <file> --rev <rev>")
+		}
+
+		oldTree, oldDecrypted, err := loadDiffSide(oldPath, keysOnly)
+		if err != nil {
+			return err
+		}
+		newTree, newDecrypted, err := loadDiffSide(newPath, keysOnly)
+		if err != nil {
+			return err
+		}
+
+		structureOnly := !oldDecrypted || !newDecrypted
+		if structureOnly && !keysOnly {
+			fmt.Fprintln(os.Stderr, "Could not decrypt both sides, comparing keys only")
+		}
+
+		changes := filterStructural(sopsfile.Diff(oldTree, newTree), structureOnly)
+
+		fmt.Printf("--- %s\n+++ %s\n", oldLabel, newPath)
+		printChanges(os.Stdout, changes, showValues && !structureOnly)
+
+		if len(changes) > 0 {
+			return &ExitError{Code: 1}
+		}
+		return nil
+	},
+}
+
+// loadDiffSide returns the decrypted tree of path, or its encrypted tree (plaintext keys)
+// when decryption is skipped or fails.
+func loadDiffSide(path string, keysOnly bool) (sopsfile.Branch, bool, error) {
+	if !keysOnly {
+		if tree, err := decryptTree(path); err == nil {
+			return tree, true, nil
+		}
+	}
+	f, err := sopsfile.Read(path)
+	if err != nil {
+		return nil, false, err
+	}
+	return f.Tree, false, nil
+}
+
+// filterStructural drops value changes between leaves when only keys can be compared.
+func filterStructural(changes []sopsfile.Change, structureOnly bool) []sopsfile.Change {
+	if !structureOnly {
+		return changes
+	}
+	filtered := changes[:0:0]
+	for _, c := range changes {
+		if c.Kind == sopsfile.Changed && sopsfile.IsLeaf(c.Old) && sopsfile.IsLeaf(c.New) {
+			continue
+		}
+		filtered = append(filtered, c)
+	}
+	return filtered
+}
+
+// printChanges prints one line per change, masking values unless showValues is set.
+func printChanges(w io.Writer, changes []sopsfile.Change, showValues bool) {
+	for _, c := range changes {
+		switch c.Kind {
+		case sopsfile.Added:
+			_, _ = fmt.Fprintf(w, "+ %s%s\n", c.Path, formatDiffValue(c.New, showValues))
+		case sopsfile.Removed:
+			_, _ = fmt.Fprintf(w, "- %s%s\n", c.Path, formatDiffValue(c.Old, showValues))
+		case sopsfile.Changed:
+			if showValues {
+				_, _ = fmt.Fprintf(w, "~ %s: %s -> %s\n", c.Path, diffValue(c.Old), diffValue(c.New))
+			} else {
+				_, _ = fmt.Fprintf(w, "~ %s\n", c.Path)
+			}
+		}
+	}
+}
+
+func formatDiffValue(v any, showValues bool) string {
+	if !showValues {
+		return ""
+	}
+	return ": " + diffValue(v)
+}
+
+// diffValue renders a value compactly on a single line.
+func diffValue(v any) string {
+	if branch, ok := v.(sopsfile.Branch); ok {
+		v = branch.Plain()
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return sopsfile.ScalarString(v)
+	}
+	return string(out)
+}
+
+// gitShowToTemp writes the content of file at rev to a temporary file with the same extension,
+// so sops detects the same format.
+func gitShowToTemp(rev, file string) (string, error) {
+	c := exec.Command("git", "show", rev+":./"+filepath.Base(file))
+	c.Dir = filepath.Dir(file)
+	c.Stderr = os.Stderr
+	data, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s at %s: %w", file, rev, err)
+	}
+
+	return writeTemp(data, filepath.Ext(file))
+}
+
+// writeTemp writes data to a new 0600 temporary file with the given extension, so sops
+// detects the right format. The caller removes the file.
+func writeTemp(data []byte, ext string) (string, error) {
+	tmp, err := os.CreateTemp("", "sopsy-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func init() {
+	diffCmd.Flags().String("rev", "", "compare the file against this git revision")
+	diffCmd.Flags().Bool("show-values", false, "show decrypted values instead of masking them")
+	diffCmd.Flags().Bool("keys-only", false, "compare key structure without decrypting")
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// execSameProcess replaces the sopsy process with command, so signals reach it directly.
+func execSameProcess(command, env []string) error {
+	bin, err := exec.LookPath(command[0])
+	if err != nil {
+		return err
+	}
+	if err := syscall.Exec(bin, command, env); err != nil {
+		return fmt.Errorf("failed to exec %s: %w", command[0], err)
+	}
+	return nil
+}
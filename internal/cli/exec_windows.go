@@ -0,0 +1,16 @@
+//go:build windows
+
+package cli
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+var forwardedSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// execSameProcess is not available on Windows, which has no exec(2).
+func execSameProcess(_, _ []string) error {
+	return fmt.Errorf("--same-process is not supported on Windows")
+}
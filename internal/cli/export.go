@@ -0,0 +1,232 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+// Export formats.
+const (
+	exportDotenv       = "dotenv"
+	exportJSON         = "json"
+	exportShell        = "shell"
+	exportGitHubEnv    = "github-env"
+	exportGitLabDotenv = "gitlab-dotenv"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Short: "Export decrypted secrets as dotenv, JSON, shell or CI variables",
+	Long: `Decrypt a file with the resolved profile and write its values as flat
+key/value pairs, quoted for the target format.
+
+Formats:
+  dotenv         KEY='value' lines
+  json           a flat JSON object
+  shell          export KEY='value' statements for eval
+  github-env     entries for $GITHUB_ENV, plus ::add-mask:: for every value on stdout
+  gitlab-dotenv  KEY=value lines for a GitLab dotenv report (no multiline values)
+
+In github-env mode the entries are appended to --output, or to $GITHUB_ENV when
+--output is not set, so that the masks printed on stdout never reach the env file.
+
+Examples:
+  eval "$(sopsy export secrets.yaml --format shell)"
+  sopsy export secrets.yaml --format github-env
+  sopsy export secrets.yaml --format gitlab-dotenv -o build.env`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		output, _ := cmd.Flags().GetString("output")
+		separator, _ := cmd.Flags().GetString("separator")
+		prefix, _ := cmd.Flags().GetString("prefix")
+		only, _ := cmd.Flags().GetStringSlice("only")
+		except, _ := cmd.Flags().GetStringSlice("except")
+
+		switch format {
+		case exportDotenv, exportJSON, exportShell, exportGitHubEnv, exportGitLabDotenv:
+		default:
+			return fmt.Errorf("unsupported format: %s (supported: %s, %s, %s, %s, %s)", format,
+				exportDotenv, exportJSON, exportShell, exportGitHubEnv, exportGitLabDotenv)
+		}
+
+		tree, err := decryptTree(args[0])
+		if err != nil {
+			return err
+		}
+		items, err := secretItems(sopsfile.Flatten(tree, separator), prefix, only, except)
+		if err != nil {
+			return err
+		}
+
+		if format == exportGitHubEnv {
+			if output == "" {
+				output = os.Getenv("GITHUB_ENV")
+			}
+			if output == "" {
+				return fmt.Errorf("github-env needs --output or $GITHUB_ENV")
+			}
+			writeGitHubMasks(os.Stdout, items)
+		}
+
+		var buf bytes.Buffer
+		if err := writeExport(&buf, format, items); err != nil {
+			return err
+		}
+
+		if output == "" {
+			_, err = os.Stdout.Write(buf.Bytes())
+			return err
+		}
+		return writeSecretFile(output, buf.Bytes(), format == exportGitHubEnv)
+	},
+}
+
+// writeExport writes items in the given format.
+func writeExport(w io.Writer, format string, items []sopsfile.Item) error {
+	if format == exportJSON {
+		return writeJSONExport(w, items)
+	}
+
+	for _, item := range items {
+		name, value := item.Key, sopsfile.ScalarString(item.Value)
+		if !envNamePattern.MatchString(name) {
+			return fmt.Errorf("key %q is not a valid variable name for %s", name, format)
+		}
+
+		switch format {
+		case exportDotenv:
+			_, _ = fmt.Fprintf(w, "%s=%s\n", name, dotenvQuote(value))
+		case exportShell:
+			_, _ = fmt.Fprintf(w, "export %s=%s\n", name, shellQuote(value))
+		case exportGitHubEnv:
+			delim, err := heredocDelimiter(value)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(w, "%s<<%s\n%s\n%s\n", name, delim, value, delim)
+		case exportGitLabDotenv:
+			if strings.ContainsAny(value, "\r\n") {
+				return fmt.Errorf("key %q: gitlab-dotenv does not support multiline values", name)
+			}
+			_, _ = fmt.Fprintf(w, "%s=%s\n", name, value)
+		}
+	}
+	return nil
+}
+
+// writeJSONExport writes items as a flat JSON object, keeping document order.
+func writeJSONExport(w io.Writer, items []sopsfile.Item) error {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, item := range items {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(item.Key)
+		value, _ := json.Marshal(sopsfile.ScalarString(item.Value))
+		fmt.Fprintf(&buf, "\n  %s: %s", key, value)
+	}
+	if len(items) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("}\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeGitHubMasks prints an ::add-mask:: workflow command for every line of every value.
+func writeGitHubMasks(w io.Writer, items []sopsfile.Item) {
+	for _, item := range items {
+		for _, line := range strings.Split(sopsfile.ScalarString(item.Value), "\n") {
+			line = strings.TrimSuffix(line, "\r")
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			_, _ = fmt.Fprintf(w, "::add-mask::%s\n", escapeWorkflowData(line))
+		}
+	}
+}
+
+// escapeWorkflowData escapes a value for a GitHub Actions workflow command.
+func escapeWorkflowData(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+// envNamePattern matches names that are valid shell and CI variable names.
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dotenvQuote quotes s for dotenv files. Single quotes disable interpolation in common
+// dotenv parsers; values that contain single quotes or newlines use escaped double quotes.
+func dotenvQuote(s string) string {
+	if !strings.ContainsAny(s, "'\r\n") {
+		return "'" + s + "'"
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "\n", `\n`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// heredocDelimiter returns a random delimiter that does not occur in value.
+func heredocDelimiter(value string) (string, error) {
+	for {
+		b := make([]byte, 8)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		delim := "SOPSY_EOF_" + hex.EncodeToString(b)
+		if !strings.Contains(value, delim) {
+			return delim, nil
+		}
+	}
+}
+
+// writeSecretFile writes data to path, restricting it to 0600 permissions.
+// In append mode the data is added to the end of an existing file.
+func writeSecretFile(path string, data []byte, appendMode bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendMode {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	if !appendMode {
+		if err := f.Chmod(0600); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to restrict permissions of %s: %w", path, err)
+		}
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func init() {
+	exportCmd.Flags().StringP("format", "f", exportDotenv, "output format: dotenv, json, shell, github-env, gitlab-dotenv")
+	exportCmd.Flags().StringP("output", "o", "", "write to this file (0600) instead of stdout")
+	exportCmd.Flags().String("separator", "_", "separator for nested keys")
+	exportCmd.Flags().String("prefix", "", "prefix for variable names")
+	exportCmd.Flags().StringSlice("only", nil, "only export keys matching these glob patterns")
+	exportCmd.Flags().StringSlice("except", nil, "skip keys matching these glob patterns")
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+func TestDotenvQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "", want: `''`},
+		{in: "plain", want: `'plain'`},
+		{in: "with space", want: `'with space'`},
+		{in: `$HOME`, want: `'$HOME'`},
+		{in: `back\slash`, want: `'back\slash'`},
+		{in: `say "hi"`, want: `'say "hi"'`},
+		{in: "значение", want: `'значение'`},
+		{in: "it's", want: `"it's"`},
+		{in: "it's $HOME", want: `"it's \$HOME"`},
+		{in: "line1\nline2", want: `"line1\nline2"`},
+		{in: "crlf\r\n", want: `"crlf\r\n"`},
+		{in: "multi\n\"quoted\" \\n $x", want: `"multi\n\"quoted\" \\n \$x"`},
+	}
+	for _, tt := range tests {
+		if got := dotenvQuote(tt.in); got != tt.want {
+			t.Errorf("dotenvQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{in: "", want: `''`},
+		{in: "plain", want: `'plain'`},
+		{in: "it's", want: `'it'\''s'`},
+		{in: "''", want: `''\'''\'''`},
+		{in: `$HOME \ "x"`, want: `'$HOME \ "x"'`},
+		{in: "line1\nline2", want: "'line1\nline2'"},
+		{in: "значение", want: `'значение'`},
+	}
+	for _, tt := range tests {
+		if got := shellQuote(tt.in); got != tt.want {
+			t.Errorf("shellQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not found")
+	}
+	for _, tt := range tests {
+		out, err := exec.Command(sh, "-c", "printf '%s' "+shellQuote(tt.in)).Output()
+		if err != nil {
+			t.Fatalf("sh failed for %q: %v", tt.in, err)
+		}
+		if string(out) != tt.in {
+			t.Errorf("sh read shellQuote(%q) back as %q", tt.in, out)
+		}
+	}
+}
+
+func TestHeredocDelimiter(t *testing.T) {
+	delim, err := heredocDelimiter("value")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(delim, "SOPSY_EOF_") {
+		t.Errorf("heredocDelimiter() = %q, want a SOPSY_EOF_ prefix", delim)
+	}
+
+	// A value holding a delimiter never gets that delimiter back
+	value := "before\n" + delim + "\nafter"
+	for i := 0; i < 100; i++ {
+		got, err := heredocDelimiter(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(value, got) {
+			t.Fatalf("heredocDelimiter() = %q, which occurs in the value", got)
+		}
+	}
+}
+
+func TestWriteExport(t *testing.T) {
+	items := []sopsfile.Item{
+		{Key: "USER", Value: "bob"},
+		{Key: "PORT", Value: 5432},
+		{Key: "QUOTE", Value: "it's"},
+	}
+
+	tests := []struct {
+		format string
+		items  []sopsfile.Item
+		want   string
+	}{
+		{format: exportDotenv, items: items, want: "USER='bob'\nPORT='5432'\nQUOTE=\"it's\"\n"},
+		{format: exportShell, items: items, want: "export USER='bob'\nexport PORT='5432'\nexport QUOTE='it'\\''s'\n"},
+		{format: exportGitLabDotenv, items: items, want: "USER=bob\nPORT=5432\nQUOTE=it's\n"},
+		{format: exportJSON, items: items, want: "{\n  \"USER\": \"bob\",\n  \"PORT\": \"5432\",\n  \"QUOTE\": \"it's\"\n}\n"},
+		{format: exportJSON, items: []sopsfile.Item{{Key: "ключ", Value: "a\"b"}}, want: "{\n  \"ключ\": \"a\\\"b\"\n}\n"},
+		{format: exportJSON, want: "{}\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		if err := writeExport(&buf, tt.format, tt.items); err != nil {
+			t.Fatalf("writeExport(%s) error = %v", tt.format, err)
+		}
+		if buf.String() != tt.want {
+			t.Errorf("writeExport(%s) = %q, want %q", tt.format, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestWriteExportGitHubEnv(t *testing.T) {
+	value := "line1\nline2"
+	var buf bytes.Buffer
+	if err := writeExport(&buf, exportGitHubEnv, []sopsfile.Item{{Key: "CERT", Value: value}}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	name, delim, ok := strings.Cut(lines[0], "<<")
+	if !ok || name != "CERT" {
+		t.Fatalf("writeExport() first line = %q, want CERT<<delimiter", lines[0])
+	}
+	if last := lines[len(lines)-1]; last != delim {
+		t.Errorf("writeExport() last line = %q, want %q", last, delim)
+	}
+	if got := strings.Join(lines[1:len(lines)-1], "\n"); got != value {
+		t.Errorf("writeExport() value = %q, want %q", got, value)
+	}
+}
+
+func TestWriteExportErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		item   sopsfile.Item
+	}{
+		{name: "invalid name", format: exportDotenv, item: sopsfile.Item{Key: "DB.PASSWORD", Value: "x"}},
+		{name: "leading digit", format: exportShell, item: sopsfile.Item{Key: "1PASSWORD", Value: "x"}},
+		{name: "unicode name", format: exportGitHubEnv, item: sopsfile.Item{Key: "ключ", Value: "x"}},
+		{name: "gitlab multiline", format: exportGitLabDotenv, item: sopsfile.Item{Key: "CERT", Value: "a\nb"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := writeExport(&bytes.Buffer{}, tt.format, []sopsfile.Item{tt.item}); err == nil {
+				t.Error("writeExport() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+const (
+	gitAttributesBegin = "# BEGIN sopsy (managed by 'sopsy git setup', do not edit)"
+	gitAttributesEnd   = "# END sopsy"
+)
+
+var gitCmd = &cobra.Command{
+	Use:   "git",
+	Short: "Git integration for encrypted files",
+	Long:  `Git integration for encrypted files (setup, textconv, merge-driver).`,
+}
+
+var gitSetupCmd = &cobra.Command{
+	Use:   "setup",
+	Short: "Register the sopsy diff driver in the current repository",
+	Long: `Register a "sopsy" diff driver in the repository's git config and mark the
+files matched by the .sops.yaml creation rules in .gitattributes.
+
+git diff and git log -p then show decrypted, value-masked diffs for files one of
+your profiles can decrypt, and the ciphertext otherwise.
+
+With --merge-driver, a "sopsy" merge driver is registered too, so encrypted files
+are merged key by key instead of conflicting on ciphertext.
+
+Files are listed with git ls-files, so run it again after adding new encrypted files.
+
+Examples:
+  sopsy git setup
+  sopsy git setup --merge-driver
+  sopsy git setup --show-values   # Show decrypted values in diffs`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showValues, _ := cmd.Flags().GetBool("show-values")
+		mergeDriver, _ := cmd.Flags().GetBool("merge-driver")
+
+		root, err := gitOutput("", "rev-parse", "--show-toplevel")
+		if err != nil {
+			return fmt.Errorf("not in a git repository: %w", err)
+		}
+
+		textconv := "sopsy git textconv"
+		if showValues {
+			textconv += " --show-values"
+		}
+		if _, err := gitOutput(root, "config", "diff.sopsy.textconv", textconv); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Registered diff driver: textconv = %s\n", textconv)
+
+		attributes := " diff=sopsy"
+		if mergeDriver {
+			driver := "sopsy git merge-driver %O %A %B %P"
+			if _, err := gitOutput(root, "config", "merge.sopsy.name", "sopsy encrypted file merge"); err != nil {
+				return err
+			}
+			if _, err := gitOutput(root, "config", "merge.sopsy.driver", driver); err != nil {
+				return err
+			}
+			attributes += " merge=sopsy"
+			fmt.Printf("✓ Registered merge driver: driver = %s\n", driver)
+		}
+
+		files, err := sopsRuleFiles(root)
+		if err != nil {
+			return err
+		}
+
+		lines := make([]string, 0, len(files))
+		for _, f := range files {
+			lines = append(lines, gitAttributesPattern(f)+attributes)
+		}
+		attrPath := filepath.Join(root, ".gitattributes")
+		if err := updateManagedBlock(attrPath, lines); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Updated %s (%d files)\n", attrPath, len(files))
+
+		return nil
+	},
+}
+
+var gitTextconvCmd = &cobra.Command{
+	Use:   "textconv <file>",
+	Short: "Print a diffable form of an encrypted file (used by git)",
+	Long: `Print the decrypted keys of an encrypted file, one per line, with values
+masked by a short fingerprint that changes whenever the value changes.
+
+Files that are not encrypted, or that none of your profiles can decrypt, are
+printed unchanged.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		showValues, _ := cmd.Flags().GetBool("show-values")
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return err
+		}
+
+		out, ok := textconv(args[0], data, showValues)
+		if !ok {
+			out = data
+		}
+		_, err = os.Stdout.Write(out)
+		return err
+	},
+}
+
+// textconv decrypts an encrypted file into "path = value" lines. It returns false when the
+// file should be shown as is.
+func textconv(file string, data []byte, showValues bool) ([]byte, bool) {
+	format := sopsfile.FormatFromPath(file)
+	if _, err := sopsfile.Parse(data, format); err != nil {
+		return nil, false
+	}
+
+	profile, err := resolveProfileForFile(file)
+	if err != nil {
+		return nil, false
+	}
+	runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+	runner.Stderr = io.Discard
+	plain, err := runner.DecryptBytes(file)
+	if err != nil {
+		return nil, false
+	}
+	tree, err := sopsfile.ParseTree(plain, format)
+	if err != nil {
+		return nil, false
+	}
+
+	maskKey := profileMaskKey(profile)
+	var buf bytes.Buffer
+	sopsfile.Walk(tree, func(path sopsfile.Path, value any) {
+		if showValues {
+			fmt.Fprintf(&buf, "%s = %s\n", path, diffValue(value))
+			return
+		}
+		fmt.Fprintf(&buf, "%s = %s\n", path, maskValue(value, maskKey))
+	})
+	return buf.Bytes(), true
+}
+
+// profileMaskKey returns private key material used to fingerprint masked values, so the
+// fingerprints cannot be brute-forced by someone without the key.
+func profileMaskKey(p *config.Profile) []byte {
+	if p.Age == nil || p.Age.KeyFile == "" {
+		return nil
+	}
+	key, err := os.ReadFile(p.Age.GetKeyFilePath())
+	if err != nil {
+		return nil
+	}
+	return key
+}
+
+// maskValue hides a value behind a keyed fingerprint. Without a key, values are fully masked.
+func maskValue(value any, key []byte) string {
+	if len(key) == 0 {
+		return "<masked>"
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(sopsfile.ScalarString(value)))
+	return "<masked:" + hex.EncodeToString(mac.Sum(nil))[:8] + ">"
+}
+
+// sopsRuleFiles returns the tracked files of the repository at root that match a creation
+// rule of the .sops.yaml found from the current directory, relative to root.
+func sopsRuleFiles(root string) ([]string, error) {
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	rulesPath, err := sopsconfig.Find(wd)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := sopsconfig.Load(rulesPath)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := gitOutput(root, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, f := range strings.Split(out, "\x00") {
+		if f == "" || f == ".gitattributes" || isSopsConfigName(filepath.Base(f)) {
+			continue
+		}
+		idx, err := rules.Match(filepath.Join(root, f))
+		if err != nil {
+			return nil, err
+		}
+		if idx >= 0 {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func isSopsConfigName(name string) bool {
+	for _, n := range sopsconfig.FileNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// gitAttributesPattern anchors a repository path for .gitattributes, quoting it when needed.
+func gitAttributesPattern(file string) string {
+	pattern := "/" + filepath.ToSlash(file)
+	if strings.ContainsAny(pattern, " \t\"\\") {
+		r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\t", `\t`)
+		return `"` + r.Replace(pattern) + `"`
+	}
+	return pattern
+}
+
+// updateManagedBlock replaces the sopsy block of a .gitattributes-style file with lines,
+// keeping everything else as is.
+func updateManagedBlock(path string, lines []string) error {
+	existing, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var kept []string
+	inBlock := false
+	for _, line := range strings.Split(strings.TrimRight(string(existing), "\n"), "\n") {
+		switch {
+		case line == gitAttributesBegin:
+			inBlock = true
+		case inBlock && line == gitAttributesEnd:
+			inBlock = false
+		case !inBlock && (line != "" || len(kept) > 0):
+			kept = append(kept, line)
+		}
+	}
+
+	var b strings.Builder
+	if len(kept) > 0 {
+		b.WriteString(strings.TrimRight(strings.Join(kept, "\n"), "\n"))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(gitAttributesBegin + "\n")
+	for _, l := range lines {
+		b.WriteString(l + "\n")
+	}
+	b.WriteString(gitAttributesEnd + "\n")
+
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// gitOutput runs git in dir and returns its trimmed stdout.
+func gitOutput(dir string, args ...string) (string, error) {
+	c := exec.Command("git", args...)
+	c.Dir = dir
+	var stderr bytes.Buffer
+	c.Stderr = &stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func init() {
+	gitSetupCmd.Flags().Bool("show-values", false, "show decrypted values in diffs instead of fingerprints")
+	gitSetupCmd.Flags().Bool("merge-driver", false, "also register the sopsy merge driver")
+	gitTextconvCmd.Flags().Bool("show-values", false, "print decrypted values instead of fingerprints")
+
+	gitCmd.AddCommand(gitSetupCmd)
+	gitCmd.AddCommand(gitTextconvCmd)
+	gitCmd.AddCommand(gitMergeDriverCmd)
+}
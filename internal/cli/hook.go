@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+// hookMarker identifies hook scripts written by sopsy, so they can be replaced safely.
+const hookMarker = "# sopsy pre-commit hook (installed by 'sopsy hook install')"
+
+const hookScript = `#!/bin/sh
+` + hookMarker + `
+exec sopsy hook pre-commit
+`
+
+var hookCmd = &cobra.Command{
+	Use:   "hook",
+	Short: "Git hooks that keep plaintext secrets out of commits",
+	Long:  `Git hooks that keep plaintext secrets out of commits (pre-commit, install).`,
+}
+
+var hookPreCommitCmd = &cobra.Command{
+	Use:   "pre-commit",
+	Short: "Reject staged secret files that are not encrypted",
+	Long: `Check every staged file that should be encrypted and reject the commit when one is not.
+
+A file should be encrypted when it matches the path_regex of a .sops.yaml creation rule
+or the paths of one of your profiles. Rules without path_regex match every file and are
+not used here.
+
+The staged content of those files must be a sops document with a valid metadata block,
+and every value the encryption rules cover (encrypted_regex, unencrypted_suffix, ...)
+must be ENC[...]. This catches files decrypted in place and never re-encrypted.
+
+Install it with: sopsy hook install`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		root, err := gitOutput("", "rev-parse", "--show-toplevel")
+		if err != nil {
+			return fmt.Errorf("not in a git repository: %w", err)
+		}
+
+		out, err := gitOutput(root, "diff", "--cached", "--name-only", "-z", "--diff-filter=ACMR")
+		if err != nil {
+			return err
+		}
+
+		rules := make(map[string]*sopsconfig.Config)
+		checked, failed := 0, 0
+		for _, f := range strings.Split(out, "\x00") {
+			if f == "" || f == ".gitattributes" || isSopsConfigName(filepath.Base(f)) {
+				continue
+			}
+			guarded, err := shouldBeEncrypted(root, f, rules)
+			if err != nil {
+				return err
+			}
+			if !guarded {
+				continue
+			}
+
+			checked++
+			if problem := checkStagedFile(root, f); problem != "" {
+				failed++
+				fmt.Fprintf(os.Stderr, "✗ %s: %s\n", f, problem)
+			}
+		}
+
+		if failed > 0 {
+			fmt.Fprintf(os.Stderr, "\nCommit rejected: %d of %d secret file(s) are not properly encrypted.\n", failed, checked)
+			fmt.Fprintln(os.Stderr, "Encrypt them with 'sopsy encrypt -i <file>' and stage them again.")
+			return &ExitError{Code: 1}
+		}
+		if checked > 0 {
+			fmt.Printf("✓ %d secret file(s) encrypted\n", checked)
+		}
+		return nil
+	},
+}
+
+var hookInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the pre-commit hook in the current repository",
+	Long: `Install a pre-commit hook that runs 'sopsy hook pre-commit'.
+
+The hook is written to the repository's hooks directory (core.hooksPath is respected).
+An existing hook that was not installed by sopsy is kept unless --force is set.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		force, _ := cmd.Flags().GetBool("force")
+
+		hookPath, err := gitOutput("", "rev-parse", "--git-path", "hooks/pre-commit")
+		if err != nil {
+			return fmt.Errorf("not in a git repository: %w", err)
+		}
+		hookPath, err = filepath.Abs(hookPath)
+		if err != nil {
+			return err
+		}
+
+		existing, err := os.ReadFile(hookPath)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", hookPath, err)
+		}
+		if len(existing) > 0 && !strings.Contains(string(existing), hookMarker) && !force {
+			return fmt.Errorf("%s already exists\nUse --force to replace it, or add 'sopsy hook pre-commit' to it", hookPath)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(hookPath), 0755); err != nil {
+			return fmt.Errorf("failed to create hooks directory: %w", err)
+		}
+		if err := os.WriteFile(hookPath, []byte(hookScript), 0755); err != nil {
+			return fmt.Errorf("failed to write %s: %w", hookPath, err)
+		}
+		// WriteFile keeps the mode of an existing file
+		if err := os.Chmod(hookPath, 0755); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Installed pre-commit hook: %s\n", hookPath)
+		return nil
+	},
+}
+
+// shouldBeEncrypted reports whether f, relative to root, matches a .sops.yaml creation rule
+// with a path_regex or a profile's path patterns. Loaded .sops.yaml files are cached in rules.
+func shouldBeEncrypted(root, f string, rules map[string]*sopsconfig.Config) (bool, error) {
+	for _, p := range cfg.ListProfiles() {
+		if p.MatchesPath(filepath.ToSlash(f)) {
+			return true, nil
+		}
+	}
+
+	abs := filepath.Join(root, f)
+	rulesPath, err := sopsconfig.Find(filepath.Dir(abs))
+	if err != nil {
+		return false, nil
+	}
+	c, ok := rules[rulesPath]
+	if !ok {
+		if c, err = sopsconfig.Load(rulesPath); err != nil {
+			return false, err
+		}
+		rules[rulesPath] = c
+	}
+
+	idx, err := c.Match(abs)
+	if err != nil || idx < 0 {
+		return false, err
+	}
+	return c.CreationRules[idx].PathRegex != "", nil
+}
+
+// checkStagedFile returns what is wrong with the staged content of f, or "" when it is
+// properly encrypted.
+func checkStagedFile(root, f string) string {
+	data, err := gitOutput(root, "show", ":"+f)
+	if err != nil {
+		return err.Error()
+	}
+
+	file, err := sopsfile.Parse([]byte(data), sopsfile.FormatFromPath(f))
+	if errors.Is(err, sopsfile.ErrNotEncrypted) {
+		return "not encrypted (no sops metadata)"
+	}
+	if err != nil {
+		return fmt.Sprintf("failed to parse: %v", err)
+	}
+	if err := file.Metadata.Validate(); err != nil {
+		return err.Error()
+	}
+
+	plain, err := sopsfile.PlaintextValues(file.Tree, file.Metadata.Rules())
+	if err != nil {
+		return err.Error()
+	}
+	if len(plain) > 0 {
+		names := make([]string, len(plain))
+		for i, p := range plain {
+			names[i] = p.String()
+		}
+		return fmt.Sprintf("plaintext value(s) at %s", strings.Join(names, ", "))
+	}
+	return ""
+}
+
+func init() {
+	hookInstallCmd.Flags().Bool("force", false, "replace an existing pre-commit hook")
+
+	hookCmd.AddCommand(hookPreCommitCmd)
+	hookCmd.AddCommand(hookInstallCmd)
+}
@@ -0,0 +1,276 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+)
+
+var profileImportCmd = &cobra.Command{
+	Use:   "import --from-sops-yaml <path>",
+	Short: "Create profiles from the creation rules of a .sops.yaml",
+	Long: `Propose a profile for each distinct set of age recipients in a .sops.yaml and add them.
+
+Profiles are named after the path_regex of their first rule, and take the rule's
+encrypted/unencrypted regex and suffix as sops options. When one of the recipients is
+the public key of a local key file, the profile's key_file is set so it can decrypt too.
+
+Key files are looked up in SOPS_AGE_KEY_FILE, the sops default location, ~/.sops/*.txt,
+the key files of existing profiles and --key-file. Recipient sets that an existing
+profile already covers are skipped.
+
+Examples:
+  sopsy profile import --from-sops-yaml .sops.yaml --dry-run
+  sopsy profile import --from-sops-yaml .sops.yaml --key-file '~/keys/*.txt'`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from-sops-yaml")
+		keyFiles, _ := cmd.Flags().GetStringSlice("key-file")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		if from == "" {
+			return fmt.Errorf("--from-sops-yaml is required")
+		}
+		rules, err := sopsconfig.Load(from)
+		if err != nil {
+			return err
+		}
+
+		proposals := proposeProfiles(rules.CreationRules, localKeyFiles(keyFiles))
+		if len(proposals) == 0 {
+			fmt.Println("No new recipient sets found")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tRECIPIENTS\tKEY FILE\tRULES")
+		for _, p := range proposals {
+			keyFile := p.profile.Age.KeyFile
+			if keyFile == "" {
+				keyFile = "-"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%d\t%s\t%s\n", p.profile.Name, len(p.profile.Age.Recipients), keyFile, strings.Join(p.rules, ", "))
+		}
+		_ = w.Flush()
+
+		if dryRun {
+			return nil
+		}
+
+		for _, p := range proposals {
+			if err := cfg.AddProfile(p.profile); err != nil {
+				return err
+			}
+		}
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		if err := config.Save(cfg, path); err != nil {
+			return err
+		}
+
+		fmt.Printf("✓ Imported %d profile(s)\n", len(proposals))
+		return nil
+	},
+}
+
+// profileProposal is a profile built from one or more creation rules.
+type profileProposal struct {
+	profile *config.Profile
+	// rules describes the source rules by their path_regex
+	rules []string
+}
+
+// proposeProfiles groups creation rules by recipient set. keyFiles maps public keys to
+// local key files.
+func proposeProfiles(rules []sopsconfig.CreationRule, keyFiles map[string]string) []*profileProposal {
+	taken := make(map[string]bool)
+	covered := make(map[string]bool)
+	for _, p := range cfg.ListProfiles() {
+		taken[p.Name] = true
+		if p.Age != nil {
+			if keys, err := p.Age.GetAllPublicKeys(); err == nil {
+				covered[recipientSetKey(keys)] = true
+			}
+		}
+	}
+
+	var proposals []*profileProposal
+	bySet := make(map[string]*profileProposal)
+	for i, rule := range rules {
+		recipients := rule.AgeRecipients()
+		if len(recipients) == 0 {
+			continue
+		}
+		set := recipientSetKey(recipients)
+		if covered[set] {
+			continue
+		}
+
+		source := rule.PathRegex
+		if source == "" {
+			source = "(any file)"
+		}
+		if p, ok := bySet[set]; ok {
+			p.rules = append(p.rules, source)
+			continue
+		}
+
+		name := uniqueName(profileNameFromRegex(rule.PathRegex, i), taken)
+		taken[name] = true
+
+		profile := &config.Profile{
+			Name:        name,
+			Description: "Imported from .sops.yaml",
+			Age:         &config.AgeConfig{Recipients: dedupe(recipients)},
+			SOPS: config.SOPSOptions{
+				EncryptedRegex:    rule.EncryptedRegex,
+				EncryptedSuffix:   rule.EncryptedSuffix,
+				UnencryptedRegex:  rule.UnencryptedRegex,
+				UnencryptedSuffix: rule.UnencryptedSuffix,
+			},
+		}
+		for _, r := range profile.Age.Recipients {
+			if keyFile, ok := keyFiles[r]; ok {
+				profile.Age.KeyFile = keyFile
+				break
+			}
+		}
+
+		p := &profileProposal{profile: profile, rules: []string{source}}
+		bySet[set] = p
+		proposals = append(proposals, p)
+	}
+	return proposals
+}
+
+// localKeyFiles maps the public keys of local age key files to their path. extra are
+// additional files or glob patterns.
+func localKeyFiles(extra []string) map[string]string {
+	var candidates []string
+	if env := os.Getenv("SOPS_AGE_KEY_FILE"); env != "" {
+		candidates = append(candidates, env)
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+			candidates = append(candidates, filepath.Join(xdg, "sops", "age", "keys.txt"))
+		} else {
+			candidates = append(candidates, filepath.Join(home, ".config", "sops", "age", "keys.txt"))
+		}
+		if runtime.GOOS == "darwin" {
+			candidates = append(candidates, filepath.Join(home, "Library", "Application Support", "sops", "age", "keys.txt"))
+		}
+		matches, _ := filepath.Glob(filepath.Join(home, ".sops", "*.txt"))
+		candidates = append(candidates, matches...)
+	}
+	for _, p := range cfg.ListProfiles() {
+		if p.Age != nil && p.Age.KeyFile != "" {
+			candidates = append(candidates, p.Age.GetKeyFilePath())
+		}
+	}
+	for _, pattern := range extra {
+		if strings.HasPrefix(pattern, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				pattern = filepath.Join(home, pattern[2:])
+			}
+		}
+		matches, _ := filepath.Glob(pattern)
+		candidates = append(candidates, matches...)
+	}
+
+	keys := make(map[string]string)
+	for _, path := range candidates {
+		publicKeys, err := config.PublicKeysInFile(path)
+		if err != nil {
+			continue
+		}
+		if abs, err := filepath.Abs(path); err == nil {
+			path = abs
+		}
+		for _, k := range publicKeys {
+			if _, ok := keys[k]; !ok {
+				keys[k] = path
+			}
+		}
+	}
+	return keys
+}
+
+// importNameStopWords are path_regex words that make poor profile names.
+var importNameStopWords = map[string]bool{
+	"yaml": true, "yml": true, "json": true, "env": true, "ini": true, "txt": true,
+	"enc": true, "dec": true, "sops": true, "secret": true, "secrets": true,
+}
+
+var (
+	regexWord   = regexp.MustCompile(`[A-Za-z0-9][A-Za-z0-9_-]*`)
+	regexEscape = regexp.MustCompile(`\\.`)
+)
+
+// profileNameFromRegex names a profile after the first meaningful word of a path_regex,
+// e.g. "prod" for ^secrets/prod/.*\.yaml$.
+func profileNameFromRegex(pathRegex string, index int) string {
+	// Drop escape sequences such as \. and \d so they do not glue onto words
+	cleaned := regexEscape.ReplaceAllString(pathRegex, " ")
+	for _, word := range regexWord.FindAllString(cleaned, -1) {
+		word = strings.ToLower(strings.Trim(word, "_-"))
+		if word != "" && !importNameStopWords[word] {
+			return word
+		}
+	}
+	if pathRegex == "" {
+		return "default"
+	}
+	return "rule-" + strconv.Itoa(index+1)
+}
+
+func uniqueName(name string, taken map[string]bool) string {
+	if !taken[name] {
+		return name
+	}
+	for i := 2; ; i++ {
+		candidate := name + "-" + strconv.Itoa(i)
+		if !taken[candidate] {
+			return candidate
+		}
+	}
+}
+
+// recipientSetKey identifies a set of recipients regardless of order and duplicates.
+func recipientSetKey(recipients []string) string {
+	set := dedupe(recipients)
+	sort.Strings(set)
+	return strings.Join(set, ",")
+}
+
+func dedupe(values []string) []string {
+	seen := make(map[string]bool)
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func init() {
+	profileImportCmd.Flags().String("from-sops-yaml", "", "path of the .sops.yaml to import")
+	profileImportCmd.Flags().StringSlice("key-file", nil, "additional age key files or glob patterns to match recipients against")
+	profileImportCmd.Flags().Bool("dry-run", false, "show the proposed profiles without adding them")
+
+	profileCmd.AddCommand(profileImportCmd)
+}
@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage the age keys of profiles",
+}
+
+var keyRotateCmd = &cobra.Command{
+	Use:   "rotate <profile> [dir]",
+	Short: "Replace the age key of a profile in every file encrypted for it",
+	Long: `Rotate the age identity of a profile across the encrypted files under dir (default:
+the current directory):
+
+  1. generate a new identity with age-keygen
+  2. add its public key as a recipient of every file encrypted for the old key
+  3. switch the profile's key_file to the new identity
+  4. after confirmation, remove the old recipient from the files
+  5. archive the old key file as <key_file>.archived-<date>
+
+Progress is saved in a state file next to the config before the key is generated and
+after every file. When a step fails, fix the cause and run the same command again to
+resume where it stopped.
+
+The new key file is written next to the old one as <name>-<date>.txt unless --key-file
+is given. Creation rules in .sops.yaml are not changed; regenerate them afterwards with
+'sopsy rules generate'.
+
+Examples:
+  sopsy key rotate stg
+  sopsy key rotate prod deploy/ --key-file ~/.sops/prod-2026.txt`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newKeyFile, _ := cmd.Flags().GetString("key-file")
+		yes, _ := cmd.Flags().GetBool("yes")
+
+		profile, err := cfg.GetProfile(args[0])
+		if err != nil {
+			return err
+		}
+		if profile.Age == nil || profile.Age.KeyFile == "" {
+			return fmt.Errorf("profile '%s' has no key_file to rotate", profile.Name)
+		}
+
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		statePath := config.RotationStatePath(path, profile.Name)
+		state, err := config.LoadRotationState(statePath)
+		if err != nil {
+			return err
+		}
+
+		if state != nil {
+			fmt.Printf("Resuming rotation of profile '%s' (started %s, phase: %s)\n",
+				profile.Name, state.StartedAt.Format("2006-01-02 15:04"), state.Phase)
+		} else {
+			dir := "."
+			if len(args) == 2 {
+				dir = args[1]
+			}
+			state, err = startKeyRotation(profile, dir, newKeyFile)
+			if err != nil {
+				return err
+			}
+			// Saved before the key is generated, so an interrupted run resumes with it
+			if err := state.Save(statePath); err != nil {
+				return err
+			}
+		}
+
+		rotation := &keyRotation{state: state, statePath: statePath, configPath: path, profile: profile}
+		return rotation.run(yes)
+	},
+}
+
+// startKeyRotation picks the path of the new identity and lists the files to rotate.
+func startKeyRotation(profile *config.Profile, dir, newKeyFile string) (*config.RotationState, error) {
+	oldKeyFile := profile.Age.GetKeyFilePath()
+	oldKeys, err := config.PublicKeysInFile(oldKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(oldKeys) != 1 {
+		return nil, fmt.Errorf("key file %s must hold exactly one identity, found %d", oldKeyFile, len(oldKeys))
+	}
+	for _, p := range cfg.ListProfiles() {
+		if p.Name != profile.Name && p.Age != nil && p.Age.GetKeyFilePath() == oldKeyFile {
+			return nil, fmt.Errorf("key file %s is also used by profile '%s'", oldKeyFile, p.Name)
+		}
+	}
+
+	if newKeyFile == "" {
+		base := strings.TrimSuffix(filepath.Base(oldKeyFile), filepath.Ext(oldKeyFile))
+		newKeyFile = filepath.Join(filepath.Dir(oldKeyFile), base+"-"+time.Now().Format("20060102")+".txt")
+	}
+	newKeyFile, err = filepath.Abs(os.ExpandEnv(newKeyFile))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(newKeyFile); err == nil {
+		return nil, fmt.Errorf("%s already exists, choose another path with --key-file", newKeyFile)
+	}
+
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := listFiles(absDir)
+	if err != nil {
+		return nil, err
+	}
+	var rotate []string
+	for _, r := range scanFiles(files) {
+		for _, rec := range r.Recipients {
+			if rec.Backend == sopsfile.BackendAge && rec.ID == oldKeys[0] {
+				rotate = append(rotate, r.Path)
+				break
+			}
+		}
+	}
+	if len(rotate) == 0 {
+		return nil, fmt.Errorf("no files under %s are encrypted for profile '%s'", dir, profile.Name)
+	}
+
+	return &config.RotationState{
+		Profile:      profile.Name,
+		Phase:        config.RotationGenerate,
+		Dir:          absDir,
+		StartedAt:    time.Now(),
+		OldKeyFile:   oldKeyFile,
+		OldPublicKey: oldKeys[0],
+		NewKeyFile:   newKeyFile,
+		Files:        rotate,
+		Done:         make(map[string]bool),
+	}, nil
+}
+
+// generateAgeKey writes a new age identity to path with age-keygen.
+func generateAgeKey(path string) error {
+	keygen := cfg.Settings.AgeKeygenPath
+	if keygen == "" {
+		keygen = "age-keygen"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create key directory: %w", err)
+	}
+
+	var stderr bytes.Buffer
+	c := exec.Command(keygen, "-o", path)
+	c.Stderr = &stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("age-keygen failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return os.Chmod(path, 0600)
+}
+
+// keyRotation runs the remaining phases of a rotation, saving the state as it goes.
+type keyRotation struct {
+	state      *config.RotationState
+	statePath  string
+	configPath string
+	profile    *config.Profile
+}
+
+func (k *keyRotation) run(yes bool) error {
+	for {
+		var err error
+		switch k.state.Phase {
+		case config.RotationGenerate:
+			err = k.generateKey()
+		case config.RotationAdd:
+			err = k.updateFiles("--add-age", k.state.NewPublicKey, true, config.RotationSwitch)
+		case config.RotationSwitch:
+			err = k.switchKeyFile()
+		case config.RotationConfirm:
+			if !yes && !confirm(fmt.Sprintf("Remove the old recipient from %d file(s) and archive %s?", len(k.state.Files), k.state.OldKeyFile)) {
+				fmt.Printf("Old recipient kept. Run 'sopsy key rotate %s' again to finish.\n", k.profile.Name)
+				return nil
+			}
+			err = k.advance(config.RotationRemove)
+		case config.RotationRemove:
+			err = k.updateFiles("--rm-age", k.state.OldPublicKey, false, config.RotationArchive)
+		case config.RotationArchive:
+			return k.archive()
+		default:
+			return fmt.Errorf("unknown rotation phase %q in %s", k.state.Phase, k.statePath)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// generateKey creates the new identity and records its public key. A key file left by
+// a run interrupted after age-keygen is used as is.
+func (k *keyRotation) generateKey() error {
+	verb := "Using"
+	if _, err := os.Stat(k.state.NewKeyFile); os.IsNotExist(err) {
+		if err := generateAgeKey(k.state.NewKeyFile); err != nil {
+			return err
+		}
+		verb = "Generated"
+	}
+	newKeys, err := config.PublicKeysInFile(k.state.NewKeyFile)
+	if err != nil {
+		return err
+	}
+	if len(newKeys) != 1 {
+		return fmt.Errorf("key file %s must hold exactly one identity, found %d", k.state.NewKeyFile, len(newKeys))
+	}
+
+	k.state.NewPublicKey = newKeys[0]
+	if err := k.advance(config.RotationAdd); err != nil {
+		return err
+	}
+	fmt.Printf("✓ %s %s (%s)\n", verb, k.state.NewKeyFile, k.state.NewPublicKey)
+	return nil
+}
+
+// updateFiles adds or removes key as an age recipient of every file not done yet, then
+// moves to the next phase. Files that already have the wanted recipients are skipped, so
+// a file updated just before an interruption is not touched twice.
+func (k *keyRotation) updateFiles(flag, key string, want bool, next string) error {
+	failed := 0
+	for _, f := range k.state.Files {
+		if k.state.Done[f] {
+			continue
+		}
+
+		meta, err := sopsfile.ReadMetadata(f)
+		if err != nil {
+			failed++
+			fmt.Printf("✗ %s: %v\n", f, err)
+			continue
+		}
+		if hasRecipient(meta.AgeRecipients(), key) != want {
+			var out bytes.Buffer
+			runner := sops.NewRunner(cfg.Settings.SOPSPath, k.profile)
+			runner.Stdin = nil
+			runner.Stdout = &out
+			runner.Stderr = &out
+			if err := runner.Rotate(f, sops.Options{InPlace: true, Extra: []string{flag, key}}); err != nil {
+				failed++
+				fmt.Printf("✗ %s: %v\n", f, err)
+				if s := strings.TrimSpace(out.String()); s != "" {
+					fmt.Println("    " + strings.ReplaceAll(s, "\n", "\n    "))
+				}
+				continue
+			}
+		}
+
+		k.state.Done[f] = true
+		if err := k.state.Save(k.statePath); err != nil {
+			return err
+		}
+		fmt.Printf("✓ %s\n", f)
+	}
+
+	if failed > 0 {
+		fmt.Printf("\n%d file(s) failed. Run 'sopsy key rotate %s' again to resume.\n", failed, k.profile.Name)
+		return &ExitError{Code: 1}
+	}
+	return k.advance(next)
+}
+
+// switchKeyFile points the profile at the new identity.
+func (k *keyRotation) switchKeyFile() error {
+	k.profile.Age.KeyFile = k.state.NewKeyFile
+	for i, r := range k.profile.Age.Recipients {
+		if r == k.state.OldPublicKey {
+			k.profile.Age.Recipients[i] = k.state.NewPublicKey
+		}
+	}
+	if err := config.Save(cfg, k.configPath); err != nil {
+		return err
+	}
+	fmt.Printf("✓ Profile '%s' now uses %s\n", k.profile.Name, k.state.NewKeyFile)
+	return k.advance(config.RotationConfirm)
+}
+
+// archive renames the old key file out of the way and removes the rotation state.
+func (k *keyRotation) archive() error {
+	if _, err := os.Stat(k.state.OldKeyFile); err == nil {
+		archived := k.state.OldKeyFile + ".archived-" + time.Now().Format("20060102")
+		if err := os.Rename(k.state.OldKeyFile, archived); err != nil {
+			return fmt.Errorf("failed to archive old key: %w", err)
+		}
+		if err := os.Chmod(archived, 0400); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Archived old key as %s\n", archived)
+	}
+	if err := os.Remove(k.statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove rotation state: %w", err)
+	}
+
+	fmt.Printf("✓ Rotated profile '%s' in %d file(s)\n", k.profile.Name, len(k.state.Files))
+	fmt.Println("Update .sops.yaml rules with 'sopsy rules generate' if they list the old key.")
+	return nil
+}
+
+func (k *keyRotation) advance(phase string) error {
+	k.state.Phase = phase
+	k.state.Done = make(map[string]bool)
+	return k.state.Save(k.statePath)
+}
+
+func hasRecipient(recipients []string, key string) bool {
+	for _, r := range recipients {
+		if r == key {
+			return true
+		}
+	}
+	return false
+}
+
+// confirm asks a yes/no question on stdin; anything but y or yes is a no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N] ", question)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+func init() {
+	keyRotateCmd.Flags().String("key-file", "", "path of the new age key file (default: next to the old one)")
+	keyRotateCmd.Flags().BoolP("yes", "y", false, "remove the old recipient without asking")
+
+	keyCmd.AddCommand(keyRotateCmd)
+}
@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var gitMergeDriverCmd = &cobra.Command{
+	Use:   "merge-driver <base> <ours> <theirs> [path]",
+	Short: "Merge encrypted files key by key (used by git)",
+	Long: `Three-way merge of encrypted files, called by git as %O %A %B %P.
+
+base, ours and theirs are decrypted with their resolved profile and merged key by
+key. The result is re-encrypted for the recipients of ours and written to ours.
+
+When a value was changed differently on both sides, ours is kept, the conflicts
+are written with markers to a decrypted sidecar under .git/sopsy/conflicts/, and
+the merge is reported as conflicted.
+
+Register it with: sopsy git setup --merge-driver`,
+	Args: cobra.RangeArgs(3, 4),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		basePath, oursPath, theirsPath := args[0], args[1], args[2]
+		path := oursPath
+		if len(args) == 4 {
+			path = args[3]
+		}
+		format := sopsfile.FormatFromPath(path)
+		ext := filepath.Ext(path)
+
+		oursData, err := os.ReadFile(oursPath)
+		if err != nil {
+			return err
+		}
+		oursFile, err := sopsfile.Parse(oursData, format)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		base, err := decryptMergeSide(basePath, ext)
+		if err != nil {
+			return err
+		}
+		ours, err := decryptMergeSide(oursPath, ext)
+		if err != nil {
+			return err
+		}
+		theirs, err := decryptMergeSide(theirsPath, ext)
+		if err != nil {
+			return err
+		}
+
+		merged, conflicts := sopsfile.Merge3(base, ours, theirs)
+
+		plain, err := sopsfile.Marshal(merged, format)
+		if err != nil {
+			return err
+		}
+		tmp, err := writeTemp(plain, ext)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(tmp) }()
+
+		profile, err := resolveProfileForFile(oursPath)
+		if err != nil {
+			return err
+		}
+		runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+		if err := runner.EncryptFor(tmp, oursFile.Metadata, sops.Options{Output: oursPath}); err != nil {
+			return err
+		}
+
+		if len(conflicts) == 0 {
+			return nil
+		}
+
+		sidecar, err := writeConflictSidecar(path, conflicts)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d conflicting value(s) in %s, kept ours. Resolve using %s\n",
+			len(conflicts), path, sidecar)
+		return &ExitError{Code: 1}
+	},
+}
+
+// decryptMergeSide decrypts one side of a merge. Git passes temporary files without an
+// extension, so they are copied to a file with ext first. An empty file (no common
+// ancestor) is an empty tree.
+func decryptMergeSide(file, ext string) (sopsfile.Branch, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return sopsfile.Branch{}, nil
+	}
+
+	tmp, err := writeTemp(data, ext)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	return decryptTree(tmp)
+}
+
+// writeConflictSidecar writes the conflicting values with conflict markers inside the git
+// directory, where the plaintext cannot be committed by accident.
+func writeConflictSidecar(path string, conflicts []sopsfile.Conflict) (string, error) {
+	gitDir, err := gitOutput("", "rev-parse", "--git-dir")
+	if err != nil {
+		return "", err
+	}
+	sidecar := filepath.Join(gitDir, "sopsy", "conflicts", path)
+	if err := os.MkdirAll(filepath.Dir(sidecar), 0700); err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# Conflicting values in %s (decrypted, do not commit)\n", path)
+	fmt.Fprintf(&buf, "# The merged file keeps ours. Fix values with: sopsy set %s <path> -\n", path)
+	for _, c := range conflicts {
+		fmt.Fprintf(&buf, "\n<<<<<<< ours\n%s\n=======\n%s\n>>>>>>> theirs\n",
+			conflictSide(c.Path, c.Ours, c.InOurs), conflictSide(c.Path, c.Theirs, c.InTheirs))
+	}
+
+	if err := writeSecretFile(sidecar, buf.Bytes(), false); err != nil {
+		return "", err
+	}
+	return sidecar, nil
+}
+
+func conflictSide(path sopsfile.Path, value any, present bool) string {
+	if !present {
+		return fmt.Sprintf("%s (deleted)", path)
+	}
+	return fmt.Sprintf("%s = %s", path, diffValue(value))
+}
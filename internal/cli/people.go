@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"os"
+	"strings"
+)
+
+// recipientNames labels recipients with the people of the address book, falling back to
+// the profiles holding them.
+type recipientNames struct {
+	people   map[string]string
+	profiles map[string][]string
+}
+
+func newRecipientNames() *recipientNames {
+	return &recipientNames{people: cfg.KeyOwners(), profiles: recipientOwners()}
+}
+
+// known reports whether the recipient belongs to a person or a profile.
+func (n *recipientNames) known(key string) bool {
+	return n.people[key] != "" || len(n.profiles[key]) > 0
+}
+
+// label names a recipient: the person holding it, else its profiles, else the shortened
+// key highlighted as unknown.
+func (n *recipientNames) label(key string) string {
+	if person := n.people[key]; person != "" {
+		return person
+	}
+	if profiles := n.profiles[key]; len(profiles) > 0 {
+		return "profile " + strings.Join(profiles, "/")
+	}
+	return highlight(shortKey(key) + " (unknown)")
+}
+
+// list labels recipients, e.g. "alice, bob, ci".
+func (n *recipientNames) list(keys []string) string {
+	labels := make([]string, 0, len(keys))
+	for _, k := range keys {
+		labels = append(labels, n.label(k))
+	}
+	return strings.Join(dedupe(labels), ", ")
+}
+
+// note is the label of a recipient printed after its full key.
+func (n *recipientNames) note(key string) string {
+	if !n.known(key) {
+		return " " + highlight("(unknown)")
+	}
+	return " (" + n.label(key) + ")"
+}
+
+// shortKey abbreviates long public keys so tables stay readable.
+func shortKey(key string) string {
+	if len(key) <= 20 {
+		return key
+	}
+	return key[:12] + "…" + key[len(key)-4:]
+}
+
+// highlight renders s in bold red when stdout is a terminal and NO_COLOR is unset.
+func highlight(s string) string {
+	if os.Getenv("NO_COLOR") != "" {
+		return s
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return s
+	}
+	return "\033[1;31m" + s + "\033[0m"
+}
@@ -15,7 +15,7 @@ import (
 var profileCmd = &cobra.Command{
 	Use:   "profile",
 	Short: "Manage encryption profiles",
-	Long:  `Manage SOPS encryption profiles (add, list, show, edit, remove).`,
+	Long:  `Manage SOPS encryption profiles (add, import, list, show, edit, remove).`,
 }
 
 var profileAddCmd = &cobra.Command{
@@ -39,10 +39,17 @@ Examples:
 		description, _ := cmd.Flags().GetString("description")
 		ageKeys, _ := cmd.Flags().GetStringSlice("age")
 		ageKeyFile, _ := cmd.Flags().GetString("age-key-file")
+		paths, _ := cmd.Flags().GetStringSlice("path")
+		rotationInterval, _ := cmd.Flags().GetString("rotation-interval")
 
 		profile := &config.Profile{
-			Name:        name,
-			Description: description,
+			Name:             name,
+			Description:      description,
+			Paths:            paths,
+			RotationInterval: rotationInterval,
+		}
+		if _, err := profile.GetRotationInterval(); err != nil {
+			return err
 		}
 
 		// Add age backend
@@ -117,11 +124,38 @@ var profileShowCmd = &cobra.Command{
 		fmt.Printf("Name:        %s\n", profile.Name)
 		fmt.Printf("Description: %s\n", profile.Description)
 		fmt.Printf("Backends:    %s\n", profile.GetBackendSummary())
+		if profile.RotationInterval != "" {
+			fmt.Printf("Rotation:    every %s\n", profile.RotationInterval)
+		}
+
+		if profile.Age != nil && len(profile.Age.Members) > 0 {
+			fmt.Println("\nMembers:")
+			for _, name := range profile.Age.Members {
+				person, err := cfg.GetPerson(name)
+				if err != nil {
+					fmt.Printf("  - %s\n", highlight(name+" (not in people)"))
+					continue
+				}
+				if person.Email != "" {
+					fmt.Printf("  - %s <%s>\n", person.Name, person.Email)
+				} else {
+					fmt.Printf("  - %s\n", person.Name)
+				}
+			}
+		}
 
 		if profile.Age != nil && len(profile.Age.Recipients) > 0 {
+			names := newRecipientNames()
 			fmt.Println("\nAge Recipients:")
 			for _, r := range profile.Age.Recipients {
-				fmt.Printf("  - %s\n", r)
+				fmt.Printf("  - %s%s\n", r, names.note(r))
+			}
+		}
+
+		if len(profile.Paths) > 0 {
+			fmt.Println("\nPaths:")
+			for _, p := range profile.Paths {
+				fmt.Printf("  - %s\n", p)
 			}
 		}
 
@@ -239,10 +273,7 @@ After this, you can use standard SOPS commands:
 		}
 
 		// Output export statement for shell integration
-		if profile.Age != nil && profile.Age.KeyFile != "" {
-			keyPath := profile.Age.GetKeyFilePath()
-			fmt.Printf("export SOPS_AGE_KEY_FILE=\"%s\"\n", keyPath)
-		}
+		fmt.Print(profile.ActivationScript())
 
 		return nil
 	},
@@ -292,19 +323,25 @@ var profileCurrentCmd = &cobra.Command{
 	Hidden: true, // Internal use for shell integration
 	Args:   cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if cfg.DefaultProfile == "" {
+		path, err := configPath()
+		if err != nil {
 			return nil
 		}
 
-		profile, err := cfg.GetProfile(cfg.DefaultProfile)
-		if err != nil {
-			return nil // Silently fail if profile not found
+		// Fast path: runs on every shell startup, so avoid parsing the config
+		if script, ok := config.ReadActivationCache(path); ok {
+			fmt.Print(script)
+			return nil
 		}
 
-		if profile.Age != nil && profile.Age.KeyFile != "" {
-			keyPath := profile.Age.GetKeyFilePath()
-			fmt.Printf("export SOPS_AGE_KEY_FILE=\"%s\"\n", keyPath)
+		loaded, err := config.Load(path)
+		if err != nil {
+			return nil // Silently fail without a config
 		}
+		fmt.Print(loaded.ActivationScript())
+
+		// Refresh a stale cache (e.g. after a manual edit) for the next shell
+		_ = config.WriteActivationCache(loaded, path)
 
 		return nil
 	},
@@ -335,6 +372,8 @@ func init() {
 	profileAddCmd.Flags().String("description", "", "profile description")
 	profileAddCmd.Flags().String("age-key-file", "", "path to age key file (contains public and private keys)")
 	profileAddCmd.Flags().StringSlice("age", nil, "age recipient public keys")
+	profileAddCmd.Flags().StringSlice("path", nil, "glob pattern of files encrypted with this profile (checked by 'sopsy hook pre-commit')")
+	profileAddCmd.Flags().String("rotation-interval", "", "how long secrets may go unchanged, e.g. 90d (checked by 'sopsy stale')")
 
 	profileCmd.AddCommand(profileAddCmd)
 	profileCmd.AddCommand(profileLsCmd)
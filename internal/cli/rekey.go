@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey --from <profile> --to <profile> [path...]",
+	Short: "Re-encrypt files from one profile to another",
+	Long: `Decrypt files with the identity of the --from profile and encrypt them again for the
+recipients and SOPS options of the --to profile, keeping their format.
+
+Paths may be files or directories (default: the current directory). In directories,
+every encrypted file that the --from profile can decrypt is re-keyed.
+
+Each file is replaced atomically: the new ciphertext is written next to it and renamed
+over it, after the original is saved as <file>.bak (disable with --backup=false).
+The plaintext only exists in a private temporary file while a file is re-keyed.
+
+Examples:
+  sopsy rekey --from stg-old --to stg --dry-run
+  sopsy rekey --from stg-old --to stg deploy/stg/ secrets.stg.yaml`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fromName, _ := cmd.Flags().GetString("from")
+		toName, _ := cmd.Flags().GetString("to")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		backup, _ := cmd.Flags().GetBool("backup")
+
+		if fromName == "" || toName == "" {
+			return fmt.Errorf("--from and --to are required")
+		}
+		from, err := cfg.GetProfile(fromName)
+		if err != nil {
+			return err
+		}
+		to, err := cfg.GetProfile(toName)
+		if err != nil {
+			return err
+		}
+		if _, err := sops.EncryptFlags(to); err != nil {
+			return fmt.Errorf("profile '%s': %w", to.Name, err)
+		}
+
+		if len(args) == 0 {
+			args = []string{"."}
+		}
+		files, err := rekeyFiles(args, from)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			fmt.Printf("No files encrypted for profile '%s'\n", from.Name)
+			return nil
+		}
+
+		if dryRun {
+			for _, f := range files {
+				fmt.Printf("Would re-key %s (%s -> %s)\n", f, from.Name, to.Name)
+			}
+			return nil
+		}
+
+		failed := 0
+		for _, f := range files {
+			if err := rekeyFile(f, from, to, backup); err != nil {
+				failed++
+				fmt.Printf("✗ %s: %v\n", f, err)
+				continue
+			}
+			fmt.Printf("✓ %s\n", f)
+		}
+
+		fmt.Printf("\n%d re-keyed, %d failed\n", len(files)-failed, failed)
+		if failed > 0 {
+			return &ExitError{Code: 1}
+		}
+		return nil
+	},
+}
+
+// rekeyFiles expands paths into the encrypted files the profile can decrypt. Files given
+// explicitly must be encrypted for it.
+func rekeyFiles(paths []string, from *config.Profile) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() {
+			meta, err := sopsfile.ReadMetadata(path)
+			if err != nil {
+				return nil, err
+			}
+			if !from.MatchesRecipients(meta.AgeRecipients()) {
+				return nil, fmt.Errorf("%s is not encrypted for profile '%s'", path, from.Name)
+			}
+			files = append(files, path)
+			continue
+		}
+
+		listed, err := listFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range scanFiles(listed) {
+			var age []string
+			for _, rec := range r.Recipients {
+				if rec.Backend == sopsfile.BackendAge {
+					age = append(age, rec.ID)
+				}
+			}
+			if from.MatchesRecipients(age) {
+				files = append(files, r.Path)
+			}
+		}
+	}
+	sort.Strings(files)
+	return dedupe(files), nil
+}
+
+// rekeyFile decrypts file with from and atomically replaces it with a copy encrypted for to.
+func rekeyFile(file string, from, to *config.Profile, backup bool) error {
+	info, err := os.Stat(file)
+	if err != nil {
+		return err
+	}
+
+	decrypter := sops.NewRunner(cfg.Settings.SOPSPath, from)
+	decrypter.Stderr = io.Discard
+	plain, err := decrypter.DecryptBytes(file)
+	if err != nil {
+		return err
+	}
+	plainPath, err := writeTemp(plain, filepath.Ext(file))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(plainPath) }()
+
+	out, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".rekey-*")
+	if err != nil {
+		return err
+	}
+	outPath := out.Name()
+	_ = out.Close()
+	defer func() { _ = os.Remove(outPath) }()
+
+	// The plaintext lives in a temporary file, so have sops match creation rules against
+	// the real path: a .sops.yaml whose rules all have a path_regex would reject it
+	encrypter := sops.NewRunner(cfg.Settings.SOPSPath, to)
+	opts := sops.Options{Output: outPath, Extra: []string{"--filename-override", file}}
+	if err := encrypter.Encrypt(plainPath, opts); err != nil {
+		return err
+	}
+
+	// Make sure sops wrote a file for the new recipients before replacing anything
+	data, err := os.ReadFile(outPath)
+	if err != nil {
+		return err
+	}
+	encrypted, err := sopsfile.Parse(data, sopsfile.FormatFromPath(file))
+	if err != nil {
+		return fmt.Errorf("unexpected sops output: %w", err)
+	}
+	expected, err := profileKeys(to)
+	if err != nil {
+		return err
+	}
+	if missing := setDifference(expected, encrypted.Metadata.AgeRecipients()); len(missing) > 0 {
+		return fmt.Errorf("re-encrypted file is not encrypted for profile '%s': missing %s", to.Name, strings.Join(missing, ", "))
+	}
+	if err := os.Chmod(outPath, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	if backup {
+		original, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(file+".bak", original, info.Mode().Perm()); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	return os.Rename(outPath, file)
+}
+
+func init() {
+	rekeyCmd.Flags().String("from", "", "profile the files are encrypted for")
+	rekeyCmd.Flags().String("to", "", "profile to encrypt the files for")
+	rekeyCmd.Flags().Bool("dry-run", false, "list the files that would be re-keyed")
+	rekeyCmd.Flags().Bool("backup", true, "keep the original file as <file>.bak")
+}
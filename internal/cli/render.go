@@ -0,0 +1,365 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var renderCmd = &cobra.Command{
+	Use:   "render <template>",
+	Short: "Render a template with decrypted values",
+	Long: `Render a Go text/template with values from one or more encrypted files.
+
+Every secrets file is decrypted with its own resolved profile. The merged values
+are available as the template data (later files win), and through these functions:
+
+  secret "db.password"        value at a path; missing paths are an error
+  lookup "db.port"            value at a path, or empty when missing
+  hasSecret "db.port"         true if the path exists
+  default "5432" VALUE        VALUE, or the fallback when VALUE is empty
+  b64enc VALUE / b64dec VALUE base64 encoding and decoding
+
+Missing keys fail the render unless --allow-missing is set, which renders them as
+empty strings. With --output, the file is created with 0600 permissions.
+
+Examples:
+  sopsy render nginx.conf.tmpl --secrets tls.yaml -o nginx.conf
+  sopsy render app.ini.tmpl --secrets base.yaml --secrets prod.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		secretFiles, _ := cmd.Flags().GetStringArray("secrets")
+		output, _ := cmd.Flags().GetString("output")
+		allowMissing, _ := cmd.Flags().GetBool("allow-missing")
+
+		if len(secretFiles) == 0 {
+			return fmt.Errorf("at least one --secrets file is required")
+		}
+
+		// Later files take precedence, so search them first
+		trees := make([]sopsfile.Branch, 0, len(secretFiles))
+		data := make(map[string]any)
+		for _, file := range secretFiles {
+			tree, err := decryptTree(file)
+			if err != nil {
+				return err
+			}
+			trees = append([]sopsfile.Branch{tree}, trees...)
+			mergeValues(data, tree.Plain())
+		}
+
+		text, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		out, err := renderTemplate(filepath.Base(args[0]), string(text), trees, data, allowMissing)
+		if err != nil {
+			return err
+		}
+
+		if output == "" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		return writeSecretFile(output, out, false)
+	},
+}
+
+// renderTemplate renders text with data as the template data and functions looking up
+// values in trees, in order. With allowMissing, values the template reads but data does
+// not have are set to empty strings first, so they render as "" wherever they are used.
+func renderTemplate(name, text string, trees []sopsfile.Branch, data map[string]any, allowMissing bool) ([]byte, error) {
+	missingKey := "missingkey=error"
+	if allowMissing {
+		missingKey = "missingkey=zero"
+	}
+	tmpl, err := template.New(name).
+		Option(missingKey).
+		Funcs(renderFuncs(trees, allowMissing)).
+		Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %w", err)
+	}
+	if allowMissing {
+		f := &missingFiller{tmpl: tmpl, root: data, vars: make(map[string][]any), visiting: make(map[string]bool)}
+		f.walk(tmpl.Tree.Root, []any{data})
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// renderFuncs returns the template functions for looking up values in trees, in order.
+func renderFuncs(trees []sopsfile.Branch, allowMissing bool) template.FuncMap {
+	lookup := func(expr string) (any, bool, error) {
+		path, err := sopsfile.ParsePath(expr)
+		if err != nil {
+			return nil, false, err
+		}
+		for _, tree := range trees {
+			if v, ok := sopsfile.Lookup(tree, path); ok {
+				if branch, isBranch := v.(sopsfile.Branch); isBranch {
+					return branch.Plain(), true, nil
+				}
+				return v, true, nil
+			}
+		}
+		return nil, false, nil
+	}
+
+	return template.FuncMap{
+		"secret": func(expr string) (any, error) {
+			v, ok, err := lookup(expr)
+			if err != nil {
+				return nil, err
+			}
+			if !ok && !allowMissing {
+				return nil, fmt.Errorf("secret %q not found", expr)
+			}
+			return orEmpty(v), nil
+		},
+		"lookup": func(expr string) (any, error) {
+			v, _, err := lookup(expr)
+			return orEmpty(v), err
+		},
+		"hasSecret": func(expr string) (bool, error) {
+			_, ok, err := lookup(expr)
+			return ok, err
+		},
+		"default": func(fallback, v any) any {
+			if isEmptyValue(v) {
+				return fallback
+			}
+			return v
+		},
+		"b64enc": func(v any) string {
+			return base64.StdEncoding.EncodeToString([]byte(sopsfile.ScalarString(v)))
+		},
+		"b64dec": func(v any) (string, error) {
+			out, err := base64.StdEncoding.DecodeString(sopsfile.ScalarString(v))
+			return string(out), err
+		},
+	}
+}
+
+// orEmpty returns "" for nil, so a missing value never renders as "<no value>".
+func orEmpty(v any) any {
+	if v == nil {
+		return ""
+	}
+	return v
+}
+
+// missingFiller sets the values a template reads to "" in its data when they are missing
+// or null. With missingkey=zero, text/template would otherwise hand a nil to functions and
+// print it as "<no value>". Values are found by following the template's field chains
+// (.db.password, $.db.password, $v.password, index .db "password") from the values dot
+// and variables can hold, through with, range and template calls.
+type missingFiller struct {
+	tmpl     *template.Template
+	root     map[string]any
+	vars     map[string][]any
+	visiting map[string]bool
+}
+
+func (f *missingFiller) walk(node parse.Node, dot []any) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, child := range n.Nodes {
+			f.walk(child, dot)
+		}
+	case *parse.ActionNode:
+		f.pipe(n.Pipe, dot, "")
+	case *parse.IfNode:
+		f.pipe(n.Pipe, dot, "")
+		f.walk(n.List, dot)
+		f.walk(n.ElseList, dot)
+	case *parse.WithNode:
+		f.walk(n.List, f.pipe(n.Pipe, dot, ""))
+		f.walk(n.ElseList, dot)
+	case *parse.RangeNode:
+		// Ranging over nil runs the else branch, but ranging over "" is an error
+		elems := elements(f.pipe(n.Pipe, dot, nil))
+		// range $v := ... or range $k, $v := ...; keys are not looked into
+		for i, v := range n.Pipe.Decl {
+			f.vars[v.Ident[0]] = nil
+			if i == len(n.Pipe.Decl)-1 {
+				f.vars[v.Ident[0]] = elems
+			}
+		}
+		f.walk(n.List, elems)
+		f.walk(n.ElseList, dot)
+	case *parse.TemplateNode:
+		t := f.tmpl.Lookup(n.Name)
+		if t == nil || t.Tree == nil || f.visiting[n.Name] {
+			return
+		}
+		f.visiting[n.Name] = true
+		f.walk(t.Tree.Root, f.pipe(n.Pipe, dot, ""))
+		f.visiting[n.Name] = false
+	}
+}
+
+// pipe fills the values read by a pipeline and returns the values it can evaluate to.
+// A missing value the pipeline evaluates to is set to leaf, and left missing if leaf is nil.
+func (f *missingFiller) pipe(p *parse.PipeNode, dot []any, leaf any) []any {
+	if p == nil {
+		return nil
+	}
+	var result []any
+	for i, cmd := range p.Cmds {
+		if i < len(p.Cmds)-1 {
+			result = f.command(cmd, dot, "")
+		} else {
+			result = f.command(cmd, dot, leaf)
+		}
+	}
+	if len(p.Cmds) > 1 {
+		result = nil
+	}
+	for _, v := range p.Decl {
+		f.vars[v.Ident[0]] = result
+	}
+	return result
+}
+
+func (f *missingFiller) command(cmd *parse.CommandNode, dot []any, leaf any) []any {
+	if len(cmd.Args) == 1 {
+		return f.arg(cmd.Args[0], dot, leaf)
+	}
+	if id, ok := cmd.Args[0].(*parse.IdentifierNode); ok && id.Ident == "index" && len(cmd.Args) > 2 {
+		var keys []string
+		for _, a := range cmd.Args[2:] {
+			if s, ok := a.(*parse.StringNode); ok {
+				keys = append(keys, s.Text)
+			}
+		}
+		if len(keys) == len(cmd.Args)-2 {
+			return fill(f.arg(cmd.Args[1], dot, ""), keys, leaf)
+		}
+	}
+	for _, a := range cmd.Args {
+		f.arg(a, dot, "")
+	}
+	return nil
+}
+
+func (f *missingFiller) arg(node parse.Node, dot []any, leaf any) []any {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return dot
+	case *parse.FieldNode:
+		return fill(dot, n.Ident, leaf)
+	case *parse.VariableNode:
+		base := f.vars[n.Ident[0]]
+		if n.Ident[0] == "$" {
+			base = []any{f.root}
+		}
+		return fill(base, n.Ident[1:], leaf)
+	case *parse.ChainNode:
+		return fill(f.arg(n.Node, dot, ""), n.Field, leaf)
+	case *parse.PipeNode:
+		return f.pipe(n, dot, leaf)
+	}
+	return nil
+}
+
+// fill follows keys from each of values, creating missing maps on the way and setting
+// a missing or null last key to leaf. It returns the values found at the end of keys.
+func fill(values []any, keys []string, leaf any) []any {
+	if len(keys) == 0 {
+		return values
+	}
+	var out []any
+	for _, v := range values {
+		m, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		next, ok := m[keys[0]]
+		if !ok || next == nil {
+			next = leaf
+			if len(keys) > 1 {
+				next = make(map[string]any)
+			}
+			if next == nil {
+				continue
+			}
+			m[keys[0]] = next
+		}
+		out = append(out, fill([]any{next}, keys[1:], leaf)...)
+	}
+	return out
+}
+
+// elements returns the values range iterates over in each of values, with nulls set to "".
+func elements(values []any) []any {
+	var out []any
+	for _, v := range values {
+		switch t := v.(type) {
+		case map[string]any:
+			for k, e := range t {
+				if e == nil {
+					t[k] = ""
+				}
+				out = append(out, t[k])
+			}
+		case []any:
+			for i, e := range t {
+				if e == nil {
+					t[i] = ""
+				}
+				out = append(out, t[i])
+			}
+		}
+	}
+	return out
+}
+
+// isEmptyValue reports whether v is nil, or the zero value of its type.
+func isEmptyValue(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	default:
+		return rv.IsZero()
+	}
+}
+
+// mergeValues deep-merges src into dst. Values from src win.
+func mergeValues(dst, src map[string]any) {
+	for k, v := range src {
+		srcMap, srcIsMap := v.(map[string]any)
+		dstMap, dstIsMap := dst[k].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[k] = v
+	}
+}
+
+func init() {
+	renderCmd.Flags().StringArray("secrets", nil, "encrypted file with values (repeatable)")
+	renderCmd.Flags().StringP("output", "o", "", "write to this file (0600) instead of stdout")
+	renderCmd.Flags().Bool("allow-missing", false, "render missing keys as empty instead of failing")
+}
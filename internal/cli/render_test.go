@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+const renderSecrets = `
+db:
+    user: bob
+    password: p4ss
+    port: 5432
+    replica: null
+users:
+    - name: alice
+      token: t1
+    - name: carol
+tags: [a, b]
+`
+
+func TestRenderTemplate(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		allowMissing bool
+		want         string
+		wantErr      string
+	}{
+		// Values that exist render the same in both modes
+		{name: "field", text: `{{ .db.user }}`, want: "bob"},
+		{name: "field allow missing", text: `{{ .db.user }}`, allowMissing: true, want: "bob"},
+		{name: "secret", text: `{{ secret "db.password" }}`, want: "p4ss"},
+		{name: "lookup number", text: `{{ lookup "db.port" }}`, want: "5432"},
+		{name: "nested list", text: `{{ secret "users[0].token" }}`, want: "t1"},
+		{name: "printf", text: `{{ printf "%s:%v" .db.user .db.port }}`, want: "bob:5432"},
+		{name: "default keeps value", text: `{{ default "x" .db.user }}`, want: "bob"},
+		{name: "b64", text: `{{ b64enc .db.user | b64dec }}`, want: "bob"},
+
+		// Missing values fail by default
+		{name: "missing field", text: `{{ .db.missing }}`, wantErr: `map has no entry for key "missing"`},
+		{name: "missing secret", text: `{{ secret "db.missing" }}`, wantErr: `secret "db.missing" not found`},
+		{name: "missing in function argument", text: `{{ printf "%s" .missing }}`, wantErr: `map has no entry for key "missing"`},
+		{name: "missing in if", text: `{{ if .missing }}x{{ end }}`, wantErr: `map has no entry for key "missing"`},
+		{name: "lookup never fails", text: `[{{ lookup "db.missing" }}]`, want: "[]"},
+
+		// With --allow-missing, missing and null values are empty strings everywhere
+		{name: "allow direct", text: `[{{ .missing }}]`, allowMissing: true, want: "[]"},
+		{name: "allow nested", text: `[{{ .db.missing }}][{{ .none.deep.er }}]`, allowMissing: true, want: "[][]"},
+		{name: "allow null", text: `[{{ .db.replica }}]`, allowMissing: true, want: "[]"},
+		{name: "allow printf argument", text: `{{ printf "%s" .missing }}`, allowMissing: true, want: ""},
+		{name: "allow printf nested argument", text: `{{ printf "%s-%s" .db.user .db.missing }}`, allowMissing: true, want: "bob-"},
+		{name: "allow function argument", text: `{{ b64enc .missing }}`, allowMissing: true, want: ""},
+		{name: "allow comparison", text: `{{ if eq .missing "" }}empty{{ end }}`, allowMissing: true, want: "empty"},
+		{name: "allow parenthesized", text: `{{ printf "%s" (print .missing) }}`, allowMissing: true, want: ""},
+		{name: "allow index", text: `[{{ index .db "missing" }}]`, allowMissing: true, want: "[]"},
+		{name: "allow root variable", text: `[{{ $.missing }}]`, allowMissing: true, want: "[]"},
+		{name: "allow variable", text: `{{ $db := .db }}[{{ $db.missing }}]`, allowMissing: true, want: "[]"},
+		{name: "allow secret", text: `[{{ secret "db.missing" }}]`, allowMissing: true, want: "[]"},
+		{name: "allow default", text: `{{ default "5433" .db.missing }}`, allowMissing: true, want: "5433"},
+		{name: "allow if", text: `{{ if .missing }}yes{{ else }}no{{ end }}`, allowMissing: true, want: "no"},
+		{name: "allow if body", text: `{{ if .db }}[{{ .db.missing }}]{{ end }}`, allowMissing: true, want: "[]"},
+		{name: "allow with", text: `{{ with .db }}[{{ .missing }}]{{ end }}`, allowMissing: true, want: "[]"},
+		{name: "allow with missing", text: `{{ with .missing }}x{{ else }}none{{ end }}`, allowMissing: true, want: "none"},
+		{name: "allow range", text: `{{ range .users }}{{ .name }}={{ .token }};{{ end }}`, allowMissing: true, want: "alice=t1;carol=;"},
+		{name: "allow range variable", text: `{{ range $i, $u := .users }}{{ printf "%d:%s" $i $u.token }};{{ end }}`, allowMissing: true, want: "0:t1;1:;"},
+		{name: "allow range missing", text: `{{ range .missing }}x{{ else }}none{{ end }}`, allowMissing: true, want: "none"},
+		{name: "allow template call", text: `{{ define "db" }}[{{ .missing }}]{{ end }}{{ template "db" .db }}`, allowMissing: true, want: "[]"},
+		{name: "allow recursive template", text: `{{ define "t" }}{{ if .next }}{{ template "t" .next }}{{ end }}[{{ .v }}]{{ end }}{{ template "t" . }}`, allowMissing: true, want: "[]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tree, err := sopsfile.ParseTree([]byte(renderSecrets), sopsfile.FormatYAML)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := renderTemplate("test", tt.text, []sopsfile.Branch{tree}, tree.Plain(), tt.allowMissing)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("renderTemplate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderTemplate() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("renderTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTemplateLaterFilesWin(t *testing.T) {
+	base, _ := sopsfile.ParseTree([]byte("db:\n    user: bob\n    host: db1\n"), sopsfile.FormatYAML)
+	prod, _ := sopsfile.ParseTree([]byte("db:\n    user: prod\n"), sopsfile.FormatYAML)
+
+	data := make(map[string]any)
+	mergeValues(data, base.Plain())
+	mergeValues(data, prod.Plain())
+
+	got, err := renderTemplate("test", `{{ .db.user }}@{{ .db.host }} {{ secret "db.user" }}`, []sopsfile.Branch{prod, base}, data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "prod@db1 prod"; string(got) != want {
+		t.Errorf("renderTemplate() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+// resolveProfile returns the profile selected with --profile, or the default profile.
+func resolveProfile() (*config.Profile, error) {
+	name := profileName
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no profile selected, use --profile or run: sopsy profile use <name>")
+	}
+	return cfg.GetProfile(name)
+}
+
+// resolveProfileForFile picks the profile for an existing file. An explicit --profile
+// always wins; otherwise the file's recipients decide, preferring the default profile.
+// Files without sops metadata fall back to resolveProfile.
+func resolveProfileForFile(path string) (*config.Profile, error) {
+	if profileName != "" {
+		return resolveProfile()
+	}
+
+	meta, err := sopsfile.ReadMetadata(path)
+	if err != nil {
+		return resolveProfile()
+	}
+
+	matches := matchingProfiles(meta)
+	if len(matches) == 0 {
+		return resolveProfile()
+	}
+	for _, p := range matches {
+		if p.Name == cfg.DefaultProfile {
+			return p, nil
+		}
+	}
+	return matches[0], nil
+}
+
+// matchingProfiles returns the profiles whose key file holds an identity among the file's
+// recipients, sorted by name.
+func matchingProfiles(meta *sopsfile.Metadata) []*config.Profile {
+	recipients := meta.AgeRecipients()
+
+	var matches []*config.Profile
+	for _, p := range cfg.ListProfiles() {
+		if p.MatchesRecipients(recipients) {
+			matches = append(matches, p)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Name < matches[j].Name
+	})
+	return matches
+}
@@ -57,25 +57,25 @@ Quick start:
 		if cmd.Name() == "init" || cmd.Name() == "help" || cmd.Name() == "completion" || cmd.Name() == "version" {
 			return nil
 		}
+		// profile current loads the config itself, only when the activation cache is stale
+		if cmd == profileCurrentCmd {
+			return nil
+		}
 		if f := cmd.Flags().Lookup("version"); f != nil && f.Changed {
 			return nil
 		}
 
 		// Load configuration
-		path := cfgFile
-		if path == "" {
-			var err error
-			path, err = config.DefaultConfigPath()
-			if err != nil {
-				return err
-			}
+		path, err := configPath()
+		if err != nil {
+			return err
 		}
 
-		var err error
 		cfg, err = config.Load(path)
 		if err != nil {
-			// Allow profile and config commands without existing config
-			if cmd.Parent() != nil && (cmd.Parent().Name() == "config" || cmd.Parent().Name() == "profile") {
+			// Allow profile, config, git, hook and rules commands without existing config
+			if cmd.Parent() != nil && (cmd.Parent().Name() == "config" || cmd.Parent().Name() == "profile" ||
+				cmd.Parent().Name() == "git" || cmd.Parent().Name() == "hook" || cmd.Parent().Name() == "rules") {
 				cfg = config.NewConfig()
 				return nil
 			}
@@ -95,6 +95,46 @@ func init() {
 	rootCmd.AddCommand(profileCmd)
 	rootCmd.AddCommand(configCmd)
 	rootCmd.AddCommand(versionCmd)
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+	rootCmd.AddCommand(editCmd)
+	rootCmd.AddCommand(rotateCmd)
+	rootCmd.AddCommand(whichProfileCmd)
+	rootCmd.AddCommand(getCmd)
+	rootCmd.AddCommand(setCmd)
+	rootCmd.AddCommand(runCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(renderCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(gitCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(rulesCmd)
+	rootCmd.AddCommand(scanCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(syncCmd)
+	rootCmd.AddCommand(rekeyCmd)
+	rootCmd.AddCommand(keyCmd)
+	rootCmd.AddCommand(teamCmd)
+	rootCmd.AddCommand(accessCmd)
+	rootCmd.AddCommand(staleCmd)
+}
+
+// ExitError makes the process exit with Code without printing an error message.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// configPath returns the config file path from --config or the default location.
+func configPath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	return config.DefaultConfigPath()
 }
 
 // SetVersion sets the version string (called from main).
@@ -0,0 +1,358 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules",
+	Short: "Manage .sops.yaml creation rules",
+	Long:  `Manage .sops.yaml creation rules (generate, test).`,
+}
+
+var rulesGenerateCmd = &cobra.Command{
+	Use:   "generate [profile...]",
+	Short: "Generate .sops.yaml creation rules from profiles",
+	Long: `Build a creation rule for each profile and write it to .sops.yaml.
+
+The rule's path_regex is built from the profile's paths, its age recipients from all of
+the profile's public keys, and its encrypted_regex/suffix options from the profile's
+sops options. Without arguments, every profile with paths is used.
+
+Paths are relative to the repository root, and "**" matches any number of directories.
+When .sops.yaml is in a subdirectory, paths are rebased onto it and paths outside of it
+are left out; profiles with no paths left are skipped unless named.
+
+Generated rules are marked with a "# sopsy profile: <name>" comment and replaced in
+place on the next run. Other rules and comments are kept. New rules are inserted before
+the first rule without path_regex, so that catch-all rule does not shadow them.
+
+With --check, nothing is written and the exit code is 1 when the file is out of date.
+
+Examples:
+  sopsy rules generate
+  sopsy rules generate prod stg
+  sopsy rules generate --check   # In CI`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		check, _ := cmd.Flags().GetBool("check")
+
+		profiles, err := rulesProfiles(args)
+		if err != nil {
+			return err
+		}
+
+		if file == "" {
+			file = sopsConfigPath()
+		}
+
+		managed := make([]sopsconfig.ManagedRule, 0, len(profiles))
+		for _, p := range profiles {
+			rebased, err := rebasedProfile(p, file)
+			if errors.Is(err, errPathsOutside) && len(args) == 0 {
+				// Profiles were not named, so only generate the rules that apply here
+				fmt.Fprintf(os.Stderr, "Skipping %v\n", err)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			rule, err := profileRule(rebased)
+			if err != nil {
+				return err
+			}
+			managed = append(managed, sopsconfig.ManagedRule{Profile: p.Name, Rule: rule})
+		}
+		data, err := os.ReadFile(file)
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read %s: %w", file, err)
+		}
+
+		updated, changed, err := sopsconfig.Update(data, managed)
+		if err != nil {
+			return fmt.Errorf("failed to update %s: %w", file, err)
+		}
+
+		if len(changed) == 0 {
+			fmt.Printf("✓ %s is up to date\n", file)
+			return nil
+		}
+		if check {
+			fmt.Fprintf(os.Stderr, "✗ %s is out of date for profile(s): %s\n", file, strings.Join(changed, ", "))
+			fmt.Fprintln(os.Stderr, "Run 'sopsy rules generate' to update it.")
+			return &ExitError{Code: 1}
+		}
+
+		if err := os.WriteFile(file, updated, 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", file, err)
+		}
+		fmt.Printf("✓ Updated %s (profiles: %s)\n", file, strings.Join(changed, ", "))
+		return nil
+	},
+}
+
+var rulesTestCmd = &cobra.Command{
+	Use:   "test <path>...",
+	Short: "Show which creation rule sops applies to a path",
+	Long: `Explain which .sops.yaml creation rule sops uses for each path.
+
+The config file is found like sops does, from the current directory upwards, and the
+first rule whose path_regex matches the path relative to the config directory wins.
+For each path, the rule, its recipients with the profiles they belong to and the
+effective encryption options are printed. Rules that can never match because an
+earlier rule shadows them are reported as well.
+
+The exit code is 1 when a path matches no rule.
+
+Examples:
+  sopsy rules test secrets/prod/db.yaml
+  sopsy rules test $(git ls-files '*.enc.yaml')`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, _ := cmd.Flags().GetString("file")
+		if file == "" {
+			path, err := sopsconfig.Find(".")
+			if err != nil {
+				return err
+			}
+			file = path
+		}
+		rules, err := sopsconfig.Load(file)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Config: %s\n", rules.Path)
+
+		unmatched := 0
+		for _, path := range args {
+			matched, err := printRuleMatch(rules, path)
+			if err != nil {
+				return err
+			}
+			if !matched {
+				unmatched++
+			}
+		}
+
+		for _, s := range rules.Shadowed() {
+			fmt.Printf("\n⚠ Rule #%d (%s) is unreachable: rule #%d comes first and %s\n",
+				s.Index+1, describeRule(rules.CreationRules[s.Index]), s.By+1, s.Reason)
+		}
+
+		if unmatched > 0 {
+			return &ExitError{Code: 1}
+		}
+		return nil
+	},
+}
+
+// printRuleMatch prints the rule that applies to path and reports whether there is one.
+func printRuleMatch(rules *sopsconfig.Config, path string) (bool, error) {
+	rel, err := rules.RelPath(path)
+	if err != nil {
+		return false, err
+	}
+	idx, err := rules.Match(path)
+	if err != nil {
+		return false, err
+	}
+
+	fmt.Printf("\n%s\n", path)
+	fmt.Printf("  Matched against: %s\n", rel)
+	if idx < 0 {
+		fmt.Println("  ✗ No creation rule matches")
+		return false, nil
+	}
+
+	rule := rules.CreationRules[idx]
+	fmt.Printf("  Rule:            #%d (%s)\n", idx+1, describeRule(rule))
+
+	fmt.Println("  Recipients:")
+	names := newRecipientNames()
+	for _, r := range rule.AgeRecipients() {
+		fmt.Printf("    age  %s%s\n", r, names.note(r))
+	}
+	for _, r := range rule.PGP {
+		fmt.Printf("    pgp  %s%s\n", r, names.note(r))
+	}
+	for _, g := range rule.KeyGroups {
+		for _, r := range g.PGP {
+			fmt.Printf("    pgp  %s%s\n", r, names.note(r))
+		}
+	}
+	for _, r := range rule.KMS {
+		fmt.Printf("    kms  %s\n", r)
+	}
+	for _, r := range rule.GCPKMS {
+		fmt.Printf("    gcp_kms  %s\n", r)
+	}
+	for _, r := range rule.AzureKeyVault {
+		fmt.Printf("    azure_keyvault  %s\n", r)
+	}
+	for _, r := range rule.HCVaultTransitURI {
+		fmt.Printf("    hc_vault_transit_uri  %s\n", r)
+	}
+	if len(rule.KeyGroups) > 0 {
+		fmt.Printf("  Key groups:      %d (shamir_threshold: %d)\n", len(rule.KeyGroups), rule.ShamirThreshold)
+	}
+
+	fmt.Printf("  Encrypts:        %s\n", describeEncryption(rule))
+
+	for i := idx + 1; i < len(rules.CreationRules); i++ {
+		later := rules.CreationRules[i]
+		if ok, err := later.Matches(rel); err == nil && ok && later.PathRegex != "" {
+			fmt.Printf("  Also matches:    #%d (%s), not used\n", i+1, describeRule(later))
+		}
+	}
+	return true, nil
+}
+
+func describeRule(rule sopsconfig.CreationRule) string {
+	if rule.PathRegex == "" {
+		return "no path_regex"
+	}
+	return "path_regex: " + rule.PathRegex
+}
+
+// describeEncryption explains which values sops encrypts under the rule.
+func describeEncryption(rule sopsconfig.CreationRule) string {
+	switch {
+	case rule.EncryptedRegex != "":
+		return "keys matching encrypted_regex " + rule.EncryptedRegex
+	case rule.UnencryptedRegex != "":
+		return "keys not matching unencrypted_regex " + rule.UnencryptedRegex
+	case rule.EncryptedSuffix != "":
+		return "keys ending in encrypted_suffix " + rule.EncryptedSuffix
+	case rule.UnencryptedSuffix != "":
+		return "keys not ending in unencrypted_suffix " + rule.UnencryptedSuffix
+	}
+	return "keys not ending in " + sopsfile.DefaultUnencryptedSuffix + " (sops default)"
+}
+
+// rulesProfiles returns the named profiles, or every profile with paths.
+func rulesProfiles(names []string) ([]*config.Profile, error) {
+	if len(names) == 0 {
+		var profiles []*config.Profile
+		for _, p := range cfg.ListProfiles() {
+			if len(p.Paths) > 0 {
+				profiles = append(profiles, p)
+			}
+		}
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("no profile has paths configured")
+		}
+		sort.Slice(profiles, func(i, j int) bool {
+			return profiles[i].Name < profiles[j].Name
+		})
+		return profiles, nil
+	}
+
+	profiles := make([]*config.Profile, 0, len(names))
+	for _, name := range names {
+		p, err := cfg.GetProfile(name)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, p)
+	}
+	return profiles, nil
+}
+
+// errPathsOutside is returned by rebasedProfile when a profile has no paths under the
+// directory of the sops config.
+var errPathsOutside = errors.New("none of its paths are under the sops config directory")
+
+// profileRule builds the creation rule of a profile.
+func profileRule(p *config.Profile) (sopsconfig.CreationRule, error) {
+	if len(p.Paths) == 0 {
+		return sopsconfig.CreationRule{}, fmt.Errorf("profile '%s' has no paths to build a path_regex from", p.Name)
+	}
+	if !p.HasBackends() {
+		return sopsconfig.CreationRule{}, fmt.Errorf("profile '%s' has no encryption backend configured", p.Name)
+	}
+	keys, err := p.Age.GetAllPublicKeys()
+	if err != nil {
+		return sopsconfig.CreationRule{}, fmt.Errorf("profile '%s': %w", p.Name, err)
+	}
+
+	regexes := make([]string, len(p.Paths))
+	for i, pattern := range p.Paths {
+		regexes[i] = sopsconfig.GlobRegex(pattern)
+	}
+
+	return sopsconfig.CreationRule{
+		PathRegex:         strings.Join(regexes, "|"),
+		Age:               keys,
+		EncryptedRegex:    p.SOPS.EncryptedRegex,
+		UnencryptedRegex:  p.SOPS.UnencryptedRegex,
+		EncryptedSuffix:   p.SOPS.EncryptedSuffix,
+		UnencryptedSuffix: p.SOPS.UnencryptedSuffix,
+	}, nil
+}
+
+// rebasedProfile returns a copy of the profile whose paths, relative to the repository
+// root, are rebased onto the directory of the sops config file, since path_regex is
+// matched relative to it. Paths outside of that directory are left out.
+func rebasedProfile(p *config.Profile, file string) (*config.Profile, error) {
+	dir, err := configDir(file)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, pattern := range p.Paths {
+		if rebased, ok := sopsconfig.RebaseGlob(pattern, dir); ok {
+			paths = append(paths, rebased)
+		}
+	}
+	if len(p.Paths) > 0 && len(paths) == 0 {
+		return nil, fmt.Errorf("profile '%s': %w (%s)", p.Name, errPathsOutside, dir)
+	}
+
+	rebased := *p
+	rebased.Paths = paths
+	return &rebased, nil
+}
+
+// configDir returns the directory of a sops config file relative to the repository root,
+// slash-separated.
+func configDir(file string) (string, error) {
+	abs, err := filepath.Abs(filepath.Dir(file))
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(repoRoot(abs), abs)
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// sopsConfigPath returns the .sops.yaml found from the current directory, or one in the
+// current directory when there is none.
+func sopsConfigPath() string {
+	if path, err := sopsconfig.Find("."); err == nil {
+		return path
+	}
+	return filepath.Join(".", sopsconfig.FileNames[0])
+}
+
+func init() {
+	rulesGenerateCmd.Flags().StringP("file", "f", "", "sops config file (default: .sops.yaml found from the current directory)")
+	rulesGenerateCmd.Flags().Bool("check", false, "exit with 1 when the file is out of date instead of writing it")
+
+	rulesTestCmd.Flags().StringP("file", "f", "", "sops config file (default: .sops.yaml found from the current directory)")
+
+	rulesCmd.AddCommand(rulesGenerateCmd)
+	rulesCmd.AddCommand(rulesTestCmd)
+}
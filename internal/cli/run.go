@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var runCmd = &cobra.Command{
+	Use:     "run <file> -- <command> [args...]",
+	Aliases: []string{"exec-env"},
+	Short:   "Run a command with decrypted secrets in its environment",
+	Long: `Decrypt a dotenv, YAML or JSON file with the resolved profile and run a command
+with its values as environment variables. Plaintext is never written to disk.
+
+Nested keys are joined with --separator (db.password becomes db_password) and
+prefixed with --prefix. --only and --except take glob patterns matched against
+the joined key, before the prefix is added.
+
+By default the command runs as a child process and signals are forwarded to it.
+With --same-process, sopsy replaces itself with the command (not on Windows).
+
+Examples:
+  sopsy run secrets.env -- npm start
+  sopsy run secrets.yaml --prefix APP_ --only 'db_*' -- ./server
+  sopsy exec-env secrets.json --same-process -- python app.py`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dash := cmd.ArgsLenAtDash()
+		if dash != 1 || len(args) < 2 {
+			return fmt.Errorf("usage: sopsy run <file> -- <command> [args...]")
+		}
+		file, command := args[0], args[1:]
+
+		separator, _ := cmd.Flags().GetString("separator")
+		prefix, _ := cmd.Flags().GetString("prefix")
+		only, _ := cmd.Flags().GetStringSlice("only")
+		except, _ := cmd.Flags().GetStringSlice("except")
+		sameProcess, _ := cmd.Flags().GetBool("same-process")
+
+		tree, err := decryptTree(file)
+		if err != nil {
+			return err
+		}
+
+		items, err := secretItems(sopsfile.Flatten(tree, separator), prefix, only, except)
+		if err != nil {
+			return err
+		}
+		env := secretEnv(os.Environ(), items)
+
+		if sameProcess {
+			return execSameProcess(command, env)
+		}
+		return execChild(command, env)
+	},
+}
+
+// secretEnv returns base with the secrets set. Variables the secrets override are removed
+// first: exec keeps duplicates and getenv returns the first one, so the inherited value
+// would win over the secret in --same-process mode.
+func secretEnv(base []string, items []sopsfile.Item) []string {
+	keys := make(map[string]bool, len(items))
+	for _, item := range items {
+		keys[envKey(item.Key)] = true
+	}
+
+	env := make([]string, 0, len(base)+len(items))
+	for _, kv := range base {
+		name, _, _ := strings.Cut(kv, "=")
+		if !keys[envKey(name)] {
+			env = append(env, kv)
+		}
+	}
+	for _, item := range items {
+		env = append(env, item.Key+"="+sopsfile.ScalarString(item.Value))
+	}
+	return env
+}
+
+// envKey normalizes a variable name for comparison; Windows names are case-insensitive.
+func envKey(name string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(name)
+	}
+	return name
+}
+
+// secretItems applies the key filters and prefix to flattened secrets.
+func secretItems(items []sopsfile.Item, prefix string, only, except []string) ([]sopsfile.Item, error) {
+	selected := make([]sopsfile.Item, 0, len(items))
+	for _, item := range items {
+		if len(only) > 0 && !matchAny(only, item.Key) {
+			continue
+		}
+		if matchAny(except, item.Key) {
+			continue
+		}
+
+		name := prefix + item.Key
+		if name == "" || strings.ContainsAny(name, "=\x00") {
+			return nil, fmt.Errorf("key %q cannot be used as a variable name", name)
+		}
+		selected = append(selected, sopsfile.Item{Key: name, Value: item.Value})
+	}
+	return selected, nil
+}
+
+// matchAny returns true if name matches any of the glob patterns.
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// execChild runs command as a child process, forwarding signals and its exit code.
+func execChild(command, env []string) error {
+	c := exec.Command(command[0], command[1:]...)
+	c.Env = env
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+
+	if err := c.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %w", command[0], err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, forwardedSignals...)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			_ = c.Process.Signal(sig)
+		}
+	}()
+
+	err := c.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+func init() {
+	runCmd.Flags().String("separator", "_", "separator for nested keys")
+	runCmd.Flags().String("prefix", "", "prefix for environment variable names")
+	runCmd.Flags().StringSlice("only", nil, "only export keys matching these glob patterns")
+	runCmd.Flags().StringSlice("except", nil, "skip keys matching these glob patterns")
+	runCmd.Flags().Bool("same-process", false, "replace sopsy with the command instead of running a child")
+}
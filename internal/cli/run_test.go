@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+func TestSecretEnv(t *testing.T) {
+	base := []string{"PATH=/usr/bin", "DB_PASSWORD=inherited", "HOME=/root", "EMPTY="}
+	items := []sopsfile.Item{
+		{Key: "DB_PASSWORD", Value: "p4ss"},
+		{Key: "QUOTED", Value: `it's "quoted"`},
+		{Key: "MULTILINE", Value: "line1\nline2\n"},
+		{Key: "DOLLAR", Value: `$HOME \$PATH\`},
+		{Key: "PORT", Value: 5432},
+		{Key: "ключ", Value: "значение"},
+		{Key: "EMPTY", Value: nil},
+	}
+
+	want := []string{
+		"PATH=/usr/bin",
+		"HOME=/root",
+		"DB_PASSWORD=p4ss",
+		`QUOTED=it's "quoted"`,
+		"MULTILINE=line1\nline2\n",
+		`DOLLAR=$HOME \$PATH\`,
+		"PORT=5432",
+		"ключ=значение",
+		"EMPTY=",
+	}
+	if got := secretEnv(base, items); !reflect.DeepEqual(got, want) {
+		t.Errorf("secretEnv() = %q, want %q", got, want)
+	}
+}
+
+func TestSecretItems(t *testing.T) {
+	items := []sopsfile.Item{
+		{Key: "DB_USER", Value: "bob"},
+		{Key: "DB_PASSWORD", Value: "p4ss"},
+		{Key: "API_TOKEN", Value: "t0k"},
+		{Key: "ключ", Value: "значение"},
+	}
+
+	tests := []struct {
+		name         string
+		prefix       string
+		only, except []string
+		items        []sopsfile.Item
+		want         []string
+		wantErr      bool
+	}{
+		{name: "all", want: []string{"DB_USER", "DB_PASSWORD", "API_TOKEN", "ключ"}},
+		{name: "prefix", prefix: "APP_", want: []string{"APP_DB_USER", "APP_DB_PASSWORD", "APP_API_TOKEN", "APP_ключ"}},
+		{name: "only", only: []string{"DB_*"}, want: []string{"DB_USER", "DB_PASSWORD"}},
+		{name: "except", except: []string{"DB_*", "ключ"}, want: []string{"API_TOKEN"}},
+		{name: "only and except", only: []string{"DB_*"}, except: []string{"*PASSWORD"}, want: []string{"DB_USER"}},
+		{name: "filters match keys before the prefix", prefix: "APP_", only: []string{"API_*"}, want: []string{"APP_API_TOKEN"}},
+		{name: "no match", only: []string{"NOPE"}, want: []string{}},
+		{name: "equals sign", items: []sopsfile.Item{{Key: "A=B", Value: "x"}}, wantErr: true},
+		{name: "nul byte", items: []sopsfile.Item{{Key: "A\x00B", Value: "x"}}, wantErr: true},
+		{name: "empty name", items: []sopsfile.Item{{Key: "", Value: "x"}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := items
+			if tt.items != nil {
+				in = tt.items
+			}
+			got, err := secretItems(in, tt.prefix, tt.only, tt.except)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("secretItems() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			keys := make([]string, 0, len(got))
+			for _, item := range got {
+				keys = append(keys, item.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("secretItems() keys = %q, want %q", keys, tt.want)
+			}
+		})
+	}
+}
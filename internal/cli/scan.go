@@ -0,0 +1,340 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var scanCmd = &cobra.Command{
+	Use:   "scan [dir]",
+	Short: "List the encrypted files in a directory tree",
+	Long: `Find every SOPS-encrypted file under dir (default: the current directory) and report
+its format, recipients and the people and profiles holding them, whether the current profile can
+decrypt it, its lastmodified date and the creation rule of the nearest .sops.yaml.
+
+Inside a git repository, files ignored by .gitignore are skipped. Files are read in
+parallel and never decrypted.
+
+Examples:
+  sopsy scan
+  sopsy scan deploy/ --json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		asJSON, _ := cmd.Flags().GetBool("json")
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		files, err := listFiles(dir)
+		if err != nil {
+			return err
+		}
+		results := scanFiles(files)
+
+		if asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(results)
+		}
+		printScan(results)
+		return nil
+	},
+}
+
+// scanResult describes one encrypted file.
+type scanResult struct {
+	Path         string          `json:"path"`
+	Format       sopsfile.Format `json:"format"`
+	Recipients   []scanRecipient `json:"recipients"`
+	Profiles     []string        `json:"profiles"`
+	People       []string        `json:"people"`
+	CanDecrypt   bool            `json:"can_decrypt"`
+	LastModified *time.Time      `json:"lastmodified,omitempty"`
+	Rule         *scanRule       `json:"rule,omitempty"`
+}
+
+type scanRecipient struct {
+	Backend  string   `json:"backend"`
+	ID       string   `json:"id"`
+	Profiles []string `json:"profiles,omitempty"`
+	Person   string   `json:"person,omitempty"`
+}
+
+type scanRule struct {
+	Config    string `json:"config"`
+	Index     int    `json:"index"`
+	PathRegex string `json:"path_regex,omitempty"`
+	// Profile is set for rules generated by 'sopsy rules generate'
+	Profile string `json:"profile,omitempty"`
+
+	rule *sopsconfig.CreationRule
+}
+
+// listFiles returns the files under dir. Inside a git repository, git lists them so
+// that .gitignore is respected; otherwise the tree is walked.
+func listFiles(dir string) ([]string, error) {
+	if out, err := gitOutput(dir, "ls-files", "-z", "--cached", "--others", "--exclude-standard"); err == nil {
+		var files []string
+		for _, f := range strings.Split(out, "\x00") {
+			if f != "" {
+				files = append(files, filepath.Join(dir, f))
+			}
+		}
+		return files, nil
+	}
+
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// scanFiles reads files in parallel and returns the encrypted ones, sorted by path.
+func scanFiles(files []string) []scanResult {
+	// Resolve profile keys once; GetAllPublicKeys reads key files
+	owners := recipientOwners()
+	people := cfg.KeyOwners()
+	// Only the identities of the key file decrypt; recipients and members are others' keys
+	current := make(map[string]bool)
+	if p, err := resolveProfile(); err == nil && p.Age != nil && p.Age.KeyFile != "" {
+		if keys, err := config.PublicKeysInFile(p.Age.KeyFile); err == nil {
+			for _, k := range keys {
+				current[k] = true
+			}
+		}
+	}
+	rules := &ruleCache{
+		configs: make(map[string]*sopsconfig.Config),
+		dirs:    make(map[string]*sopsconfig.Config),
+	}
+
+	jobs := make(chan string)
+	var (
+		mu      sync.Mutex
+		results []scanResult
+		wg      sync.WaitGroup
+	)
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range jobs {
+				r, ok := scanFile(path, owners, people, current, rules)
+				if !ok {
+					continue
+				}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, f := range files {
+		jobs <- f
+	}
+	close(jobs)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Path < results[j].Path
+	})
+	return results
+}
+
+func scanFile(path string, owners map[string][]string, people map[string]string, current map[string]bool, rules *ruleCache) (scanResult, bool) {
+	data, err := os.ReadFile(path)
+	// Cheap check before parsing: every sops file contains its metadata key
+	if err != nil || !bytes.Contains(data, []byte("sops")) {
+		return scanResult{}, false
+	}
+	format := sopsfile.FormatFromPath(path)
+	f, err := sopsfile.Parse(data, format)
+	if err != nil {
+		return scanResult{}, false
+	}
+
+	r := scanResult{Path: path, Format: format, Recipients: []scanRecipient{}, Profiles: []string{}, People: []string{}}
+	seen := make(map[string]bool)
+	seenPeople := make(map[string]bool)
+	for backend, ids := range f.Metadata.Recipients() {
+		for _, id := range ids {
+			rec := scanRecipient{Backend: backend, ID: id, Person: people[id]}
+			if rec.Person != "" && !seenPeople[rec.Person] {
+				seenPeople[rec.Person] = true
+				r.People = append(r.People, rec.Person)
+			}
+			if backend == sopsfile.BackendAge {
+				rec.Profiles = owners[id]
+				if current[id] {
+					r.CanDecrypt = true
+				}
+				for _, name := range rec.Profiles {
+					if !seen[name] {
+						seen[name] = true
+						r.Profiles = append(r.Profiles, name)
+					}
+				}
+			}
+			r.Recipients = append(r.Recipients, rec)
+		}
+	}
+	sort.Slice(r.Recipients, func(i, j int) bool {
+		if r.Recipients[i].Backend != r.Recipients[j].Backend {
+			return r.Recipients[i].Backend < r.Recipients[j].Backend
+		}
+		return r.Recipients[i].ID < r.Recipients[j].ID
+	})
+	sort.Strings(r.Profiles)
+	sort.Strings(r.People)
+
+	if !f.Metadata.LastModified.IsZero() {
+		t := f.Metadata.LastModified
+		r.LastModified = &t
+	}
+	r.Rule = rules.match(path)
+	return r, true
+}
+
+// recipientOwners maps age public keys to the names of the profiles holding them.
+func recipientOwners() map[string][]string {
+	owners := make(map[string][]string)
+	for _, p := range cfg.ListProfiles() {
+		if p.Age == nil {
+			continue
+		}
+		keys, err := p.Age.GetAllPublicKeys()
+		if err != nil {
+			continue
+		}
+		for _, k := range keys {
+			owners[k] = append(owners[k], p.Name)
+		}
+	}
+	for _, names := range owners {
+		sort.Strings(names)
+	}
+	return owners
+}
+
+// ruleCache finds and loads the .sops.yaml of each directory once. It is safe for
+// concurrent use.
+type ruleCache struct {
+	mu      sync.Mutex
+	configs map[string]*sopsconfig.Config // by config path
+	dirs    map[string]*sopsconfig.Config // by directory, nil when there is none
+}
+
+// match returns the creation rule of the .sops.yaml nearest to path that applies to it.
+func (c *ruleCache) match(path string) *scanRule {
+	dir := filepath.Dir(path)
+	c.mu.Lock()
+	rules, ok := c.dirs[dir]
+	if !ok {
+		if rulesPath, err := sopsconfig.Find(dir); err == nil {
+			if rules, ok = c.configs[rulesPath]; !ok {
+				rules, _ = sopsconfig.Load(rulesPath)
+				c.configs[rulesPath] = rules
+			}
+		}
+		c.dirs[dir] = rules
+	}
+	c.mu.Unlock()
+
+	if rules == nil {
+		return nil
+	}
+	idx, err := rules.Match(path)
+	if err != nil || idx < 0 {
+		return nil
+	}
+	rule := &rules.CreationRules[idx]
+	return &scanRule{Config: rules.Path, Index: idx + 1, PathRegex: rule.PathRegex, Profile: rule.Profile, rule: rule}
+}
+
+func printScan(results []scanResult) {
+	if len(results) == 0 {
+		fmt.Println("No encrypted files found")
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	// RECIPIENTS comes last: highlighted unknown keys would upset the column widths
+	_, _ = fmt.Fprintln(w, "FILE\tFORMAT\tPROFILES\tDECRYPT\tLAST MODIFIED\tRULE\tRECIPIENTS")
+	for _, r := range results {
+		profiles := strings.Join(r.Profiles, ",")
+		if profiles == "" {
+			profiles = "-"
+		}
+		decrypt := "no"
+		if r.CanDecrypt {
+			decrypt = "yes"
+		}
+		modified := "-"
+		if r.LastModified != nil {
+			modified = r.LastModified.Format("2006-01-02")
+		}
+		rule := "-"
+		if r.Rule != nil {
+			rule = fmt.Sprintf("#%d", r.Rule.Index)
+			if r.Rule.PathRegex != "" {
+				rule += " " + r.Rule.PathRegex
+			}
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Path, r.Format, profiles, decrypt, modified, rule, recipientList(r))
+	}
+	_ = w.Flush()
+}
+
+// recipientList names the age and pgp recipients of a file by person, then by profile.
+// Recipients nobody holds are highlighted.
+func recipientList(r scanResult) string {
+	var labels []string
+	for _, rec := range r.Recipients {
+		switch {
+		case rec.Backend != sopsfile.BackendAge && rec.Backend != sopsfile.BackendPGP:
+			continue
+		case rec.Person != "":
+			labels = append(labels, rec.Person)
+		case len(rec.Profiles) > 0:
+			labels = append(labels, "profile "+strings.Join(rec.Profiles, "/"))
+		default:
+			labels = append(labels, highlight(shortKey(rec.ID)+" (unknown)"))
+		}
+	}
+	if len(labels) == 0 {
+		return "-"
+	}
+	return strings.Join(dedupe(labels), ", ")
+}
+
+func init() {
+	scanCmd.Flags().Bool("json", false, "print the results as JSON")
+}
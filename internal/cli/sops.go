@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt <file> [-- sops-args...]",
+	Short: "Encrypt a file with the active profile",
+	Long: `Encrypt a file with sops, using the recipients and SOPS options of the active profile.
+
+The profile is taken from --profile, or the default profile.
+Arguments after -- are passed to sops unchanged.
+
+Examples:
+  sopsy encrypt secrets.yaml                 # Print encrypted file to stdout
+  sopsy encrypt -i secrets.yaml              # Encrypt in place
+  sopsy encrypt secrets.yaml -o secrets.enc.yaml
+  sopsy -p prod encrypt -i secrets.yaml -- --input-type yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runner, file, opts, err := sopsInvocation(cmd, args)
+		if err != nil {
+			return err
+		}
+		return runner.Encrypt(file, opts)
+	},
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <file> [-- sops-args...]",
+	Short: "Decrypt a file with the active profile",
+	Long: `Decrypt a file with sops, using the age key file of the active profile.
+
+Without --profile, the profile is detected from the file's recipients,
+preferring the default profile when it matches.
+
+Examples:
+  sopsy decrypt secrets.yaml                 # Print plaintext to stdout
+  sopsy decrypt -i secrets.yaml              # Decrypt in place
+  sopsy decrypt secrets.yaml -o plain.yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runner, file, opts, err := sopsInvocation(cmd, args)
+		if err != nil {
+			return err
+		}
+		return runner.Decrypt(file, opts)
+	},
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit <file> [-- sops-args...]",
+	Short: "Edit an encrypted file with the active profile",
+	Long: `Open an encrypted file in $EDITOR through sops.
+
+New files are created for the recipients and SOPS options of the active profile.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runner, file, opts, err := sopsInvocation(cmd, args)
+		if err != nil {
+			return err
+		}
+		return runner.Edit(file, opts)
+	},
+}
+
+var rotateCmd = &cobra.Command{
+	Use:   "rotate <file> [-- sops-args...]",
+	Short: "Rotate the data key of an encrypted file",
+	Long: `Generate a new data key for an encrypted file and re-encrypt it, using
+the age key file of the active profile.
+
+Examples:
+  sopsy rotate -i secrets.yaml`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		runner, file, opts, err := sopsInvocation(cmd, args)
+		if err != nil {
+			return err
+		}
+		return runner.Rotate(file, opts)
+	},
+}
+
+// sopsInvocation resolves the profile, target file and options shared by the sops wrappers.
+func sopsInvocation(cmd *cobra.Command, args []string) (*sops.Runner, string, sops.Options, error) {
+	var opts sops.Options
+
+	fileArgs := args
+	if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+		fileArgs = args[:dash]
+		opts.Extra = args[dash:]
+	}
+	if len(fileArgs) != 1 {
+		return nil, "", opts, fmt.Errorf("expected exactly one file, got %d", len(fileArgs))
+	}
+
+	if cmd.Flags().Lookup("in-place") != nil {
+		opts.InPlace, _ = cmd.Flags().GetBool("in-place")
+	}
+	if cmd.Flags().Lookup("output") != nil {
+		opts.Output, _ = cmd.Flags().GetString("output")
+	}
+	if opts.InPlace && opts.Output != "" {
+		return nil, "", opts, fmt.Errorf("--in-place and --output are mutually exclusive")
+	}
+
+	profile, err := resolveProfileForFile(fileArgs[0])
+	if err != nil {
+		return nil, "", opts, err
+	}
+
+	return sops.NewRunner(cfg.Settings.SOPSPath, profile), fileArgs[0], opts, nil
+}
+
+// decryptTree decrypts file with its resolved profile and parses the plaintext.
+func decryptTree(file string) (sopsfile.Branch, error) {
+	profile, err := resolveProfileForFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+	data, err := runner.DecryptBytes(file)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := sopsfile.ParseTree(data, sopsfile.FormatFromPath(file))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return tree, nil
+}
+
+func init() {
+	for _, c := range []*cobra.Command{encryptCmd, decryptCmd, rotateCmd} {
+		c.Flags().BoolP("in-place", "i", false, "write the result back to the file")
+		c.Flags().StringP("output", "o", "", "write the result to this path")
+	}
+}
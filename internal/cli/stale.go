@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var staleCmd = &cobra.Command{
+	Use:   "stale [dir]",
+	Short: "List secrets overdue for rotation",
+	Long: `Report the encrypted files under dir (default: the current directory) whose secrets
+have not changed for longer than their rotation threshold, grouped by profile.
+
+A file's age is its sops lastmodified date. With --keys, the git history of each file is
+decrypted revision by revision to find when every single value last changed, so a file
+whose other values were edited recently still reports its old ones.
+
+The threshold is --older-than when given, else the rotation_interval of the file's
+profile (the profile its paths or creation rule bind it to, else the profile holding
+its recipients). Files without a threshold are not checked.
+
+Intervals are written like 180d, 12w, 1y or 720h. The exit code is 1 when anything is
+overdue, so it can run as a scheduled CI job.
+
+Examples:
+  sopsy stale --older-than 180d
+  sopsy stale deploy/prod/ --keys`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		olderThan, _ := cmd.Flags().GetString("older-than")
+		perKey, _ := cmd.Flags().GetBool("keys")
+
+		var threshold time.Duration
+		if olderThan != "" {
+			d, err := config.ParseInterval(olderThan)
+			if err != nil {
+				return fmt.Errorf("--older-than: %w", err)
+			}
+			threshold = d
+		}
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		files, err := listFiles(dir)
+		if err != nil {
+			return err
+		}
+		root := repoRoot(dir)
+		now := time.Now()
+
+		groups := make(map[string][]staleFile)
+		intervals := make(map[string]time.Duration)
+		checked, unchecked := 0, 0
+		for _, r := range scanFiles(files) {
+			profile := staleProfile(r, root)
+			limit := threshold
+			group := "(no profile)"
+			if profile != nil {
+				group = profile.Name
+				if limit == 0 {
+					if limit, err = profile.GetRotationInterval(); err != nil {
+						return err
+					}
+				}
+			}
+			if limit == 0 || r.LastModified == nil {
+				unchecked++
+				continue
+			}
+			checked++
+			intervals[group] = limit
+
+			s := staleFile{path: r.Path, modified: *r.LastModified}
+			if perKey {
+				history, err := keyHistory(r.Path, *r.LastModified)
+				if err != nil {
+					groups[group] = append(groups[group], staleFile{path: r.Path, err: err})
+					continue
+				}
+				for _, k := range history {
+					if now.Sub(k.changed) > limit {
+						s.keys = append(s.keys, k)
+					}
+				}
+				if len(s.keys) == 0 {
+					continue
+				}
+			} else if now.Sub(s.modified) <= limit {
+				continue
+			}
+			groups[group] = append(groups[group], s)
+		}
+
+		if unchecked > 0 {
+			fmt.Fprintf(os.Stderr, "%d file(s) not checked: no --older-than, rotation_interval or lastmodified\n", unchecked)
+		}
+		if len(groups) == 0 {
+			fmt.Printf("✓ %d file(s) within their rotation interval\n", checked)
+			return nil
+		}
+
+		names := make([]string, 0, len(groups))
+		for name := range groups {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		overdue := 0
+		for _, name := range names {
+			fmt.Printf("%s (older than %s)\n", name, formatDays(intervals[name]))
+			for _, s := range groups[name] {
+				overdue++
+				if s.err != nil {
+					fmt.Printf("  ✗ %s: history unavailable: %v\n", s.path, s.err)
+					continue
+				}
+				fmt.Printf("  ✗ %s  last modified %s (%s ago)\n", s.path, s.modified.Format("2006-01-02"), formatDays(now.Sub(s.modified)))
+				for _, k := range s.keys {
+					fmt.Printf("      %s  unchanged since %s (%s)\n", k.key, k.changed.Format("2006-01-02"), formatDays(now.Sub(k.changed)))
+				}
+			}
+			fmt.Println()
+		}
+		fmt.Printf("%d file(s) overdue for rotation, %d checked\n", overdue, checked)
+		return &ExitError{Code: 1}
+	},
+}
+
+// staleFile is a file overdue for rotation. With per-key history, keys holds the overdue
+// values.
+type staleFile struct {
+	path     string
+	modified time.Time
+	keys     []keyChange
+	err      error
+}
+
+// keyChange records when the value of a key last changed.
+type keyChange struct {
+	key     string
+	changed time.Time
+}
+
+// staleProfile returns the profile a file belongs to: the profile its paths or creation
+// rule bind it to, else the first profile holding its recipients.
+func staleProfile(r scanResult, root string) *config.Profile {
+	if drift, err := expectedRecipients(r, root); err == nil && drift != nil && drift.Profile != nil {
+		return drift.Profile
+	}
+	if len(r.Profiles) > 0 {
+		if p, err := cfg.GetProfile(r.Profiles[0]); err == nil {
+			return p
+		}
+	}
+	return nil
+}
+
+// keyHistory returns, for every value of file, when it last changed. Committed revisions
+// are decrypted from the newest back, until a value differs or a revision cannot be
+// decrypted. A value unchanged in a revision dates from that revision's sops lastmodified,
+// or from its commit if that is earlier. Values changed since the last commit date from
+// modified.
+func keyHistory(file string, modified time.Time) ([]keyChange, error) {
+	tree, err := decryptTree(file)
+	if err != nil {
+		return nil, err
+	}
+	current := make(map[string]any)
+	changes := make(map[string]time.Time)
+	open := make(map[string]bool)
+	items := sopsfile.Flatten(tree, ".")
+	for _, item := range items {
+		current[item.Key] = item.Value
+		changes[item.Key] = modified
+		open[item.Key] = true
+	}
+
+	out, err := gitOutput(filepath.Dir(file), "log", "--format=%H %cI", "--", filepath.Base(file))
+	if err == nil && out != "" {
+		for _, line := range strings.Split(out, "\n") {
+			if len(open) == 0 {
+				break
+			}
+			rev, date, ok := strings.Cut(line, " ")
+			if !ok {
+				continue
+			}
+			committed, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				continue
+			}
+			values, lastModified, err := revisionValues(rev, file)
+			if err != nil {
+				// Older revisions may be encrypted for keys we no longer hold
+				break
+			}
+			// Commits can be made long after the file was encrypted, e.g. in a rebase
+			changed := committed
+			if !lastModified.IsZero() && lastModified.Before(changed) {
+				changed = lastModified
+			}
+			for key := range open {
+				if v, ok := values[key]; ok && v == current[key] {
+					changes[key] = changed
+				} else {
+					delete(open, key)
+				}
+			}
+		}
+	}
+
+	history := make([]keyChange, 0, len(items))
+	for _, item := range items {
+		history = append(history, keyChange{key: item.Key, changed: changes[item.Key]})
+	}
+	return history, nil
+}
+
+// revisionValues decrypts file at a git revision and returns its flattened values and
+// sops lastmodified time.
+func revisionValues(rev, file string) (map[string]any, time.Time, error) {
+	tmp, err := gitShowToTemp(rev, file)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer func() { _ = os.Remove(tmp) }()
+
+	meta, err := sopsfile.ReadMetadata(tmp)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	tree, err := decryptTree(tmp)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	values := make(map[string]any)
+	for _, item := range sopsfile.Flatten(tree, ".") {
+		values[item.Key] = item.Value
+	}
+	return values, meta.LastModified, nil
+}
+
+// formatDays renders a duration in whole days.
+func formatDays(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	if days == 1 {
+		return "1 day"
+	}
+	return fmt.Sprintf("%d days", days)
+}
+
+func init() {
+	staleCmd.Flags().String("older-than", "", "rotation threshold, e.g. 180d (default: the profile's rotation_interval)")
+	staleCmd.Flags().Bool("keys", false, "find when each value last changed from the git history (decrypts every revision)")
+}
@@ -0,0 +1,233 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [dir]",
+	Short: "Update the keys of files whose recipients drifted",
+	Long: `Run the equivalent of 'sops updatekeys' on every encrypted file under dir (default:
+the current directory) whose age recipients differ from the expected ones, as reported
+by 'sopsy verify'. Files that are in sync are never touched.
+
+Files governed by a creation rule are updated with that rule. Files whose expected
+recipients come from a profile are updated with a temporary rule holding the profile's
+keys; their other master keys (pgp, kms, ...) are kept. The data key is not rotated.
+
+Each file is decrypted with the profile matching its current recipients. A failure
+does not stop the other files, and the exit code is 1 when any file failed.
+
+Examples:
+  sopsy sync --dry-run
+  sopsy sync deploy/ --concurrency 8`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		concurrency, _ := cmd.Flags().GetInt("concurrency")
+		if concurrency < 1 {
+			return fmt.Errorf("--concurrency must be at least 1")
+		}
+
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		files, err := listFiles(dir)
+		if err != nil {
+			return err
+		}
+		results := scanFiles(files)
+		root := repoRoot(dir)
+		names := newRecipientNames()
+
+		var jobs []syncJob
+		inSync, failed := 0, 0
+		for _, r := range results {
+			drift, err := checkDrift(r, root)
+			if err != nil {
+				failed++
+				fmt.Printf("✗ %s: %v\n", r.Path, err)
+				continue
+			}
+			if drift == nil {
+				continue
+			}
+			if drift.InSync() {
+				inSync++
+				continue
+			}
+			jobs = append(jobs, syncJob{path: r.Path, drift: drift, rule: r.Rule})
+		}
+
+		if dryRun {
+			for _, job := range jobs {
+				fmt.Printf("Would update %s (%s)\n", job.path, job.drift.Source)
+				for _, k := range job.drift.Missing {
+					fmt.Printf("    + %s%s\n", k, names.note(k))
+				}
+				for _, k := range job.drift.Extra {
+					fmt.Printf("    - %s%s\n", k, names.note(k))
+				}
+			}
+			fmt.Printf("\n%d file(s) to update, %d in sync\n", len(jobs), inSync)
+			return nil
+		}
+
+		updated := len(runSyncJobs(jobs, concurrency))
+		failed += len(jobs) - updated
+
+		fmt.Printf("\n%d updated, %d failed, %d already in sync\n", updated, failed, inSync)
+		if failed > 0 {
+			return &ExitError{Code: 1}
+		}
+		return nil
+	},
+}
+
+// syncJob is a file whose keys need updating.
+type syncJob struct {
+	path  string
+	drift *recipientDrift
+	rule  *scanRule
+}
+
+// runSyncJobs updates the keys of the files with a bounded number of concurrent sops
+// processes and returns the jobs that succeeded.
+func runSyncJobs(jobs []syncJob, concurrency int) []syncJob {
+	// Resolve profiles up front: matching reads key files and is not worth doing per worker.
+	// Files without a profile to decrypt them with fail here.
+	profiles := make([]*config.Profile, len(jobs))
+	var queued []int
+	for i, job := range jobs {
+		profile, err := resolveProfileForFile(job.path)
+		if err != nil {
+			fmt.Printf("✗ %s: %v\n", job.path, err)
+			continue
+		}
+		profiles[i] = profile
+		queued = append(queued, i)
+	}
+
+	queue := make(chan int)
+	var (
+		mu      sync.Mutex
+		updated []syncJob
+		wg      sync.WaitGroup
+	)
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				output, err := updateKeys(jobs[i], profiles[i])
+
+				mu.Lock()
+				if err != nil {
+					fmt.Printf("✗ %s: %v\n", jobs[i].path, err)
+					if s := strings.TrimSpace(output); s != "" {
+						fmt.Println("    " + strings.ReplaceAll(s, "\n", "\n    "))
+					}
+				} else {
+					updated = append(updated, jobs[i])
+					fmt.Printf("✓ %s (%s)\n", jobs[i].path, jobs[i].drift.Source)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, i := range queued {
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return updated
+}
+
+// updateKeys runs sops updatekeys for one file and returns its combined output.
+func updateKeys(job syncJob, profile *config.Profile) (string, error) {
+	configPath := ""
+	if job.drift.Profile == nil {
+		configPath = job.rule.Config
+	} else {
+		tmp, err := profileSyncConfig(job.path, job.drift.Expected)
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = os.Remove(tmp) }()
+		configPath = tmp
+	}
+
+	var out bytes.Buffer
+	runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+	runner.Stdin = nil
+	runner.Stdout = &out
+	runner.Stderr = &out
+	err := runner.UpdateKeys(job.path, configPath)
+	return out.String(), err
+}
+
+// profileSyncConfig writes a temporary sops config whose only rule has the age keys and
+// the file's other master keys. KMS encryption contexts cannot be written in such a rule,
+// so files using them are refused rather than losing their context.
+func profileSyncConfig(file string, ageKeys []string) (string, error) {
+	meta, err := sopsfile.ReadMetadata(file)
+	if err != nil {
+		return "", err
+	}
+	if len(meta.KeyGroups) > 1 {
+		return "", fmt.Errorf("file uses key groups, update it with 'sops updatekeys'")
+	}
+
+	recipients := meta.Recipients()
+	var kms []string
+	awsProfile := ""
+	for i, k := range meta.Groups()[0].KMS {
+		if len(k.Context) > 0 {
+			return "", fmt.Errorf("KMS key %s has an encryption context, update the file with 'sops updatekeys'", k.ARN)
+		}
+		if i > 0 && k.AWSProfile != awsProfile {
+			return "", fmt.Errorf("KMS keys use different AWS profiles, update the file with 'sops updatekeys'")
+		}
+		awsProfile = k.AWSProfile
+		if k.Role != "" {
+			kms = append(kms, k.ARN+"+"+k.Role)
+		} else {
+			kms = append(kms, k.ARN)
+		}
+	}
+	rule := sopsconfig.CreationRule{
+		Age:               ageKeys,
+		PGP:               recipients[sopsfile.BackendPGP],
+		KMS:               kms,
+		AWSProfile:        awsProfile,
+		GCPKMS:            recipients[sopsfile.BackendGCPKMS],
+		AzureKeyVault:     recipients[sopsfile.BackendAzureKV],
+		HCVaultTransitURI: recipients[sopsfile.BackendHCVault],
+	}
+	data, err := yaml.Marshal(sopsconfig.Config{CreationRules: []sopsconfig.CreationRule{rule}})
+	if err != nil {
+		return "", err
+	}
+	return writeTemp(data, ".yaml")
+}
+
+func init() {
+	syncCmd.Flags().Bool("dry-run", false, "show which files would be updated without changing them")
+	syncCmd.Flags().Int("concurrency", runtime.NumCPU(), "number of files updated in parallel")
+}
@@ -0,0 +1,365 @@
+package cli
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+)
+
+var teamCmd = &cobra.Command{
+	Use:   "team",
+	Short: "Add and remove people from profiles",
+	Long:  `Manage which people of the address book are members of a profile (add, remove).`,
+}
+
+var teamAddCmd = &cobra.Command{
+	Use:   "add <person> --to <profile> [dir]",
+	Short: "Make a person a member of a profile",
+	Long: `Add a person to the members of a profile and update what depends on its recipients:
+
+  - rules generated for the profile in .sops.yaml ('sopsy rules generate') are updated
+  - with --reencrypt, every file under dir (default: the current directory) that should
+    be encrypted for the profile gets the person's keys, like 'sopsy sync' does
+
+A person who is not in the address book yet is created from --age, --ssh and --email.
+
+Examples:
+  sopsy team add alice --to prod --age age1...
+  sopsy team add alice --to prod --reencrypt`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		to, _ := cmd.Flags().GetString("to")
+		email, _ := cmd.Flags().GetString("email")
+		ageKeys, _ := cmd.Flags().GetStringSlice("age")
+		sshKeys, _ := cmd.Flags().GetStringSlice("ssh")
+		reencrypt, _ := cmd.Flags().GetBool("reencrypt")
+
+		if to == "" {
+			return fmt.Errorf("--to is required")
+		}
+		profile, err := cfg.GetProfile(to)
+		if err != nil {
+			return err
+		}
+
+		name := args[0]
+		person, ok := cfg.People[name]
+		if !ok {
+			if len(ageKeys) == 0 && len(sshKeys) == 0 {
+				return fmt.Errorf("person '%s' is not in the address book, give their keys with --age or --ssh", name)
+			}
+			person = &config.Person{Name: name}
+			cfg.People[name] = person
+		}
+		keyCount := len(person.Age) + len(person.SSH)
+		person.Age = dedupe(append(person.Age, ageKeys...))
+		person.SSH = dedupe(append(person.SSH, sshKeys...))
+		personChanged := len(person.Age)+len(person.SSH) != keyCount || (email != "" && email != person.Email)
+		if email != "" {
+			person.Email = email
+		}
+
+		added := cfg.AddMember(profile, name)
+		if !added && !personChanged {
+			fmt.Printf("%s is already a member of profile '%s'\n", name, profile.Name)
+			return nil
+		}
+
+		if err := saveTeamChange(profile); err != nil {
+			return err
+		}
+		if added {
+			fmt.Printf("✓ Added %s to profile '%s'\n", name, profile.Name)
+		} else {
+			fmt.Printf("✓ Updated %s, already a member of profile '%s'\n", name, profile.Name)
+		}
+
+		dir := "."
+		if len(args) == 2 {
+			dir = args[1]
+		}
+		if err := regenerateProfileRules(dir, profile); err != nil {
+			return err
+		}
+		if !reencrypt {
+			fmt.Println("Files keep their recipients until updated: run 'sopsy sync' or pass --reencrypt.")
+			return nil
+		}
+		_, err = reencryptProfileFiles(dir, profile)
+		return err
+	},
+}
+
+var teamRemoveCmd = &cobra.Command{
+	Use:   "remove <person> --from <profile> [dir]",
+	Short: "Remove a person from a profile",
+	Long: `Remove a person from the members of a profile, together with any of their keys
+listed as explicit recipients, and update what depends on its recipients:
+
+  - rules generated for the profile in .sops.yaml ('sopsy rules generate') are updated
+  - with --reencrypt, the person's keys are removed from every file under dir (default:
+    the current directory) that should be encrypted for the profile
+
+Removing a recipient does not change the data key of a file, so the person can still
+decrypt any copy they kept. After --reencrypt, you are asked whether to rotate the data
+keys of the updated files with 'sops rotate' (--rotate does it without asking).
+
+The person stays in the address book.
+
+Examples:
+  sopsy team remove bob --from prod --reencrypt
+  sopsy team remove bob --from prod --reencrypt --rotate`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		from, _ := cmd.Flags().GetString("from")
+		reencrypt, _ := cmd.Flags().GetBool("reencrypt")
+		rotate, _ := cmd.Flags().GetBool("rotate")
+
+		if from == "" {
+			return fmt.Errorf("--from is required")
+		}
+		profile, err := cfg.GetProfile(from)
+		if err != nil {
+			return err
+		}
+		person, err := cfg.GetPerson(args[0])
+		if err != nil {
+			return err
+		}
+		if rotate && !reencrypt {
+			return fmt.Errorf("--rotate requires --reencrypt")
+		}
+
+		removed := false
+		if profile.Age != nil {
+			members := profile.Age.Members[:0]
+			for _, m := range profile.Age.Members {
+				if m == person.Name {
+					removed = true
+					continue
+				}
+				members = append(members, m)
+			}
+			profile.Age.Members = members
+
+			keys := person.Keys()
+			recipients := profile.Age.Recipients[:0]
+			for _, r := range profile.Age.Recipients {
+				if containsName(keys, r) {
+					removed = true
+					continue
+				}
+				recipients = append(recipients, r)
+			}
+			profile.Age.Recipients = recipients
+		}
+		if !removed {
+			return fmt.Errorf("%s is not a member of profile '%s'", person.Name, profile.Name)
+		}
+		if !profile.HasBackends() {
+			return fmt.Errorf("profile '%s' would have no recipients left", profile.Name)
+		}
+
+		if err := saveTeamChange(profile); err != nil {
+			return err
+		}
+		fmt.Printf("✓ Removed %s from profile '%s'\n", person.Name, profile.Name)
+
+		dir := "."
+		if len(args) == 2 {
+			dir = args[1]
+		}
+		if err := regenerateProfileRules(dir, profile); err != nil {
+			return err
+		}
+
+		if !reencrypt {
+			fmt.Printf("\n⚠ Files still list %s as a recipient until updated: run 'sopsy sync' or pass --reencrypt.\n", person.Name)
+			fmt.Println("  Then rotate their data keys with 'sops rotate -i <file>': removing a recipient does not")
+			fmt.Printf("  change the data key, and %s may have kept decrypted copies.\n", person.Name)
+			return nil
+		}
+
+		// Files that were updated still need their data keys rotated when others failed;
+		// the update error is returned after that
+		updated, err := reencryptProfileFiles(dir, profile)
+		if len(updated) == 0 {
+			return err
+		}
+
+		fmt.Printf("\n⚠ %s could decrypt these files before. Their data keys are unchanged until rotated.\n", person.Name)
+		if !rotate && !confirm(fmt.Sprintf("Rotate the data keys of %d file(s) now?", len(updated))) {
+			fmt.Println("Rotate them later with 'sops rotate -i <file>'.")
+			return err
+		}
+		if rotateErr := rotateDataKeys(updated); rotateErr != nil {
+			return rotateErr
+		}
+		return err
+	},
+}
+
+// saveTeamChange checks that the profile's members resolve and writes the config.
+func saveTeamChange(profile *config.Profile) error {
+	if _, err := profile.Age.GetAllPublicKeys(); err != nil {
+		return fmt.Errorf("profile '%s': %w", profile.Name, err)
+	}
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	return config.Save(cfg, path)
+}
+
+// regenerateProfileRules updates the rule generated for profile in the .sops.yaml found
+// from dir. Files without such a rule are left alone.
+func regenerateProfileRules(dir string, profile *config.Profile) error {
+	file, err := sopsconfig.Find(dir)
+	if err != nil {
+		return nil
+	}
+	rules, err := sopsconfig.Load(file)
+	if err != nil {
+		return err
+	}
+	generated := false
+	for _, r := range rules.CreationRules {
+		if r.Profile == profile.Name {
+			generated = true
+			break
+		}
+	}
+	if !generated {
+		return nil
+	}
+
+	rebased, err := rebasedProfile(profile, file)
+	if err != nil {
+		return err
+	}
+	rule, err := profileRule(rebased)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	updated, changed, err := sopsconfig.Update(data, []sopsconfig.ManagedRule{{Profile: profile.Name, Rule: rule}})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %w", file, err)
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if err := os.WriteFile(file, updated, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", file, err)
+	}
+	fmt.Printf("✓ Updated the rule of profile '%s' in %s\n", profile.Name, file)
+	return nil
+}
+
+// reencryptProfileFiles updates the keys of the files under dir that should be encrypted
+// for profile and returns the paths that were updated.
+func reencryptProfileFiles(dir string, profile *config.Profile) ([]string, error) {
+	files, err := listFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	root := repoRoot(dir)
+
+	// Files whose recipients cannot be checked may belong to the profile, so they fail
+	// the update like in sync
+	var jobs []syncJob
+	failed := 0
+	for _, r := range scanFiles(files) {
+		drift, err := checkDrift(r, root)
+		if err != nil {
+			failed++
+			fmt.Printf("✗ %s: %v\n", r.Path, err)
+			continue
+		}
+		if drift == nil || drift.Profile == nil || drift.Profile.Name != profile.Name || drift.InSync() {
+			continue
+		}
+		jobs = append(jobs, syncJob{path: r.Path, drift: drift, rule: r.Rule})
+	}
+	if len(jobs) == 0 && failed == 0 {
+		fmt.Println("No files to update")
+		return nil, nil
+	}
+
+	fmt.Println()
+	var updated []string
+	for _, job := range runSyncJobs(jobs, runtime.NumCPU()) {
+		updated = append(updated, job.path)
+	}
+	sort.Strings(updated)
+
+	failed += len(jobs) - len(updated)
+	fmt.Printf("\n%d updated, %d failed\n", len(updated), failed)
+	if failed > 0 {
+		return updated, &ExitError{Code: 1}
+	}
+	return updated, nil
+}
+
+// rotateDataKeys runs sops rotate on each file with the profile matching its recipients.
+func rotateDataKeys(files []string) error {
+	failed := 0
+	for _, f := range files {
+		profile, _ := resolveProfileForFile(f)
+
+		var out bytes.Buffer
+		runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+		runner.Stdin = nil
+		runner.Stdout = &out
+		runner.Stderr = &out
+		if err := runner.Rotate(f, sops.Options{InPlace: true}); err != nil {
+			failed++
+			fmt.Printf("✗ %s: %v\n", f, err)
+			if s := strings.TrimSpace(out.String()); s != "" {
+				fmt.Println("    " + strings.ReplaceAll(s, "\n", "\n    "))
+			}
+			continue
+		}
+		fmt.Printf("✓ Rotated %s\n", f)
+	}
+	if failed > 0 {
+		return &ExitError{Code: 1}
+	}
+	return nil
+}
+
+func containsName(values []string, name string) bool {
+	for _, v := range values {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+func init() {
+	teamAddCmd.Flags().String("to", "", "profile to add the person to")
+	teamAddCmd.Flags().String("email", "", "email of the person")
+	teamAddCmd.Flags().StringSlice("age", nil, "age public keys of the person")
+	teamAddCmd.Flags().StringSlice("ssh", nil, "ssh public keys of the person")
+	teamAddCmd.Flags().Bool("reencrypt", false, "add the person's keys to the files of the profile")
+
+	teamRemoveCmd.Flags().String("from", "", "profile to remove the person from")
+	teamRemoveCmd.Flags().Bool("reencrypt", false, "remove the person's keys from the files of the profile")
+	teamRemoveCmd.Flags().Bool("rotate", false, "rotate the data keys of the updated files without asking")
+
+	teamCmd.AddCommand(teamAddCmd)
+	teamCmd.AddCommand(teamRemoveCmd)
+}
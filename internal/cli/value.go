@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get <file> <path>",
+	Short: "Print a single decrypted value",
+	Long: `Decrypt a single value from an encrypted file.
+
+Paths use dotted or JSONPath-like notation.
+
+Examples:
+  sopsy get secrets.yaml db.password
+  sopsy get secrets.yaml 'users[0].token'
+  sopsy get secrets.json '$["app.example.com"].key'`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := args[0]
+		path, err := sopsfile.ParsePath(args[1])
+		if err != nil {
+			return err
+		}
+
+		profile, err := resolveProfileForFile(file)
+		if err != nil {
+			return err
+		}
+
+		return sops.NewRunner(cfg.Settings.SOPSPath, profile).Extract(file, path.SOPSIndex())
+	},
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <file> <path> <value|->",
+	Short: "Set a single value in an encrypted file",
+	Long: `Set a single value in an encrypted file, keeping its format.
+
+Use - as the value to read it from stdin, so it never ends up in your shell history.
+New keys that the profile's encryption rules (encrypted_regex etc.) would leave in
+plaintext are refused.
+
+Examples:
+  sopsy set secrets.yaml db.password -
+  sopsy set secrets.yaml 'users[0].token' "$TOKEN"
+  sopsy set secrets.yaml db.port 5432 --json`,
+	Args: cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := args[0]
+		path, err := sopsfile.ParsePath(args[1])
+		if err != nil {
+			return err
+		}
+
+		profile, err := resolveProfileForFile(file)
+		if err != nil {
+			return err
+		}
+
+		encFile, err := sopsfile.Read(file)
+		if err != nil {
+			return err
+		}
+		if _, exists := sopsfile.Lookup(encFile.Tree, path); !exists {
+			if err := checkEncryptedKey(path, sops.ProfileRules(profile), encFile.Metadata.Rules()); err != nil {
+				return err
+			}
+		}
+
+		asJSON, _ := cmd.Flags().GetBool("json")
+		value, err := readValue(args[2], asJSON)
+		if err != nil {
+			return err
+		}
+
+		return sops.NewRunner(cfg.Settings.SOPSPath, profile).Set(file, path.SOPSIndex(), value)
+	},
+}
+
+// readValue returns the JSON encoding of a value argument, reading it from stdin for "-".
+func readValue(arg string, asJSON bool) ([]byte, error) {
+	value := arg
+	if arg == "-" {
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read value from stdin: %w", err)
+		}
+		value = strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	}
+
+	if asJSON {
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("value is not valid JSON")
+		}
+		return []byte(value), nil
+	}
+	return json.Marshal(value)
+}
+
+// checkEncryptedKey returns an error if any of the rule sets would store path in plaintext.
+func checkEncryptedKey(path sopsfile.Path, ruleSets ...sopsfile.Rules) error {
+	for _, rules := range ruleSets {
+		encrypted, err := rules.Encrypts(path.Keys())
+		if err != nil {
+			return err
+		}
+		if !encrypted {
+			return fmt.Errorf("refusing to create %s: it would be stored in plaintext (outside the profile's encryption rules)", path)
+		}
+	}
+	return nil
+}
+
+func init() {
+	setCmd.Flags().Bool("json", false, "interpret the value as JSON (numbers, booleans, objects)")
+}
@@ -0,0 +1,208 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify [dir]",
+	Short: "Check that encrypted files have the recipients they should have",
+	Long: `Compare the age recipients of every encrypted file under dir (default: the current
+directory) with the recipients it is expected to have, and report missing and extra
+recipients per file.
+
+The expected recipients are all public keys of, in order:
+  1. the profile whose paths match the file (relative to the repository root)
+  2. the profile a matching creation rule was generated from (sopsy rules generate)
+  3. otherwise, the age keys of the matching creation rule itself
+
+Files none of these apply to are not checked. The exit code is 1 when any file has
+drifted, so it can run in CI.
+
+Examples:
+  sopsy verify
+  sopsy verify deploy/`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) == 1 {
+			dir = args[0]
+		}
+
+		files, err := listFiles(dir)
+		if err != nil {
+			return err
+		}
+		results := scanFiles(files)
+		root := repoRoot(dir)
+		names := newRecipientNames()
+
+		ok, drifted, unchecked := 0, 0, 0
+		for _, r := range results {
+			drift, err := checkDrift(r, root)
+			if err != nil {
+				drifted++
+				fmt.Printf("✗ %s: %v\n", r.Path, err)
+				continue
+			}
+			if drift == nil {
+				unchecked++
+				continue
+			}
+			if drift.InSync() {
+				ok++
+				continue
+			}
+
+			drifted++
+			fmt.Printf("✗ %s (%s)\n", r.Path, drift.Source)
+			for _, k := range drift.Missing {
+				fmt.Printf("    missing: %s%s\n", k, names.note(k))
+			}
+			for _, k := range drift.Extra {
+				fmt.Printf("    extra:   %s%s\n", k, names.note(k))
+			}
+		}
+
+		if unchecked > 0 {
+			fmt.Fprintf(os.Stderr, "%d file(s) not checked: no profile paths or creation rule apply\n", unchecked)
+		}
+		if drifted > 0 {
+			fmt.Printf("\n%d file(s) drifted, %d up to date. Update their keys with 'sopsy sync'.\n", drifted, ok)
+			return &ExitError{Code: 1}
+		}
+		fmt.Printf("✓ %d file(s) up to date\n", ok)
+		return nil
+	},
+}
+
+// recipientDrift compares the age recipients of a file with the expected ones.
+type recipientDrift struct {
+	// Source describes where the expected recipients come from
+	Source   string
+	Expected []string
+	Missing  []string
+	Extra    []string
+	// Profile is set when the expected recipients are the keys of a profile
+	Profile *config.Profile
+}
+
+// InSync reports whether the file has exactly the expected recipients.
+func (d *recipientDrift) InSync() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0
+}
+
+// checkDrift compares a scanned file with its expected recipients. It returns nil when
+// nothing says which recipients the file should have.
+func checkDrift(r scanResult, root string) (*recipientDrift, error) {
+	drift, err := expectedRecipients(r, root)
+	if err != nil || drift == nil {
+		return nil, err
+	}
+
+	var actual []string
+	for _, rec := range r.Recipients {
+		if rec.Backend == sopsfile.BackendAge {
+			actual = append(actual, rec.ID)
+		}
+	}
+	drift.Missing = setDifference(drift.Expected, actual)
+	drift.Extra = setDifference(actual, drift.Expected)
+	return drift, nil
+}
+
+// expectedRecipients returns the age recipients a scanned file should have and where that
+// expectation comes from, or nil when nothing applies to the file.
+func expectedRecipients(r scanResult, root string) (*recipientDrift, error) {
+	if p := boundProfile(r.Path, root); p != nil {
+		keys, err := profileKeys(p)
+		return &recipientDrift{Source: "profile " + p.Name, Expected: keys, Profile: p}, err
+	}
+	if r.Rule == nil {
+		return nil, nil
+	}
+	if r.Rule.Profile != "" {
+		p, err := cfg.GetProfile(r.Rule.Profile)
+		if err != nil {
+			return nil, fmt.Errorf("rule #%d: %w", r.Rule.Index, err)
+		}
+		keys, err := profileKeys(p)
+		source := fmt.Sprintf("profile %s, rule #%d", p.Name, r.Rule.Index)
+		return &recipientDrift{Source: source, Expected: keys, Profile: p}, err
+	}
+	recipients := r.Rule.rule.AgeRecipients()
+	if len(recipients) == 0 {
+		return nil, nil
+	}
+	return &recipientDrift{Source: fmt.Sprintf("rule #%d", r.Rule.Index), Expected: recipients}, nil
+}
+
+// boundProfile returns the first profile, by name, whose paths match file.
+func boundProfile(file, root string) *config.Profile {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return nil
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return nil
+	}
+
+	profiles := cfg.ListProfiles()
+	sort.Slice(profiles, func(i, j int) bool {
+		return profiles[i].Name < profiles[j].Name
+	})
+	for _, p := range profiles {
+		if p.MatchesPath(filepath.ToSlash(rel)) {
+			return p
+		}
+	}
+	return nil
+}
+
+func profileKeys(p *config.Profile) ([]string, error) {
+	if p.Age == nil {
+		return nil, fmt.Errorf("profile '%s' has no age keys", p.Name)
+	}
+	keys, err := p.Age.GetAllPublicKeys()
+	if err != nil {
+		return nil, fmt.Errorf("profile '%s': %w", p.Name, err)
+	}
+	return keys, nil
+}
+
+// repoRoot returns the absolute root of the git repository containing dir, or dir itself.
+func repoRoot(dir string) string {
+	if root, err := gitOutput(dir, "rev-parse", "--show-toplevel"); err == nil {
+		return root
+	}
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return dir
+	}
+	return abs
+}
+
+// setDifference returns the values of a that are not in b, sorted.
+func setDifference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	var diff []string
+	for _, v := range dedupe(a) {
+		if !in[v] {
+			diff = append(diff, v)
+		}
+	}
+	sort.Strings(diff)
+	return diff
+}
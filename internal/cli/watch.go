@@ -0,0 +1,299 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sops"
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch <dir>",
+	Short: "Re-encrypt plaintext working copies when they change",
+	Long: `Watch a directory for plaintext working copies of encrypted files and re-encrypt
+them to their encrypted counterpart whenever they are saved.
+
+Plaintext copies are the files matching --pattern; the counterpart is the same name
+with --marker removed (secrets.dec.yaml is encrypted to secrets.yaml). Changes are
+polled every --interval and a file is encrypted once it has not changed for --debounce.
+Copies that are newer than their counterpart when the watch starts are encrypted too.
+
+An existing counterpart keeps its recipients, and is left untouched when its content
+did not change. A new counterpart is encrypted with, in order: --profile, the profile
+whose paths match it (relative to the repository root), the matching .sops.yaml creation rule, or the
+default profile.
+
+Examples:
+  sopsy watch .
+  sopsy watch config/ --pattern '*.plain.json' --marker .plain
+  sopsy run ci.env -- sopsy watch .   # With the environment of sopsy run`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		pattern, _ := cmd.Flags().GetString("pattern")
+		marker, _ := cmd.Flags().GetString("marker")
+		interval, _ := cmd.Flags().GetDuration("interval")
+		debounce, _ := cmd.Flags().GetDuration("debounce")
+
+		if marker == "" {
+			return fmt.Errorf("--marker must not be empty")
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid --pattern: %w", err)
+		}
+		if interval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+		if info, err := os.Stat(args[0]); err != nil {
+			return err
+		} else if !info.IsDir() {
+			return fmt.Errorf("%s is not a directory", args[0])
+		}
+
+		w := &watcher{
+			dir:      args[0],
+			root:     repoRoot(args[0]),
+			pattern:  pattern,
+			marker:   marker,
+			debounce: debounce,
+			seen:     make(map[string]watchedFile),
+			pending:  make(map[string]time.Time),
+			rules:    make(map[string]*sopsconfig.Config),
+			log:      log.New(os.Stdout, "", log.LstdFlags),
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		w.log.Printf("Watching %s for %s (Ctrl-C to stop)", w.dir, w.pattern)
+		return w.run(ctx, interval)
+	},
+}
+
+// watchedFile is the last seen state of a plaintext copy.
+type watchedFile struct {
+	modTime time.Time
+	size    int64
+}
+
+type watcher struct {
+	dir      string
+	root     string // Repository root, which profile paths are relative to
+	pattern  string
+	marker   string
+	debounce time.Duration
+
+	seen    map[string]watchedFile
+	pending map[string]time.Time
+	rules   map[string]*sopsconfig.Config
+	log     *log.Logger
+}
+
+func (w *watcher) run(ctx context.Context, interval time.Duration) error {
+	if err := w.scan(true); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			w.log.Printf("Stopped")
+			return nil
+		case now := <-ticker.C:
+			if err := w.scan(false); err != nil {
+				w.log.Printf("✗ %v", err)
+			}
+			w.flush(now)
+		}
+	}
+}
+
+// scan records plaintext copies that changed since the last scan. On the first scan,
+// copies newer than their counterpart are treated as changed.
+func (w *watcher) scan(initial bool) error {
+	now := time.Now()
+	found := make(map[string]bool)
+
+	err := filepath.WalkDir(w.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !w.isPlaintext(d.Name()) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		found[path] = true
+		state := watchedFile{modTime: info.ModTime(), size: info.Size()}
+		prev, known := w.seen[path]
+		w.seen[path] = state
+
+		switch {
+		case initial:
+			target, err := os.Stat(w.counterpart(path))
+			if err != nil || target.ModTime().Before(state.modTime) {
+				w.pending[path] = now
+			}
+		case !known || prev != state:
+			w.pending[path] = now
+		}
+		return nil
+	})
+
+	for path := range w.seen {
+		if !found[path] {
+			delete(w.seen, path)
+			delete(w.pending, path)
+		}
+	}
+	return err
+}
+
+// flush encrypts the pending copies that have not changed for the debounce period.
+func (w *watcher) flush(now time.Time) {
+	var ready []string
+	for path, changed := range w.pending {
+		if now.Sub(changed) >= w.debounce {
+			ready = append(ready, path)
+		}
+	}
+	sort.Strings(ready)
+
+	for _, path := range ready {
+		delete(w.pending, path)
+		if err := w.encrypt(path); err != nil {
+			w.log.Printf("✗ %s: %v", path, err)
+		}
+	}
+}
+
+// isPlaintext reports whether name is a plaintext copy.
+func (w *watcher) isPlaintext(name string) bool {
+	ok, _ := filepath.Match(w.pattern, name)
+	return ok && strings.Contains(name, w.marker)
+}
+
+// counterpart returns the encrypted file of a plaintext copy.
+func (w *watcher) counterpart(path string) string {
+	name := strings.Replace(filepath.Base(path), w.marker, "", 1)
+	return filepath.Join(filepath.Dir(path), name)
+}
+
+func (w *watcher) encrypt(path string) error {
+	target := w.counterpart(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	tree, err := sopsfile.ParseTree(data, sopsfile.FormatFromPath(path))
+	if err != nil {
+		return fmt.Errorf("not re-encrypted, failed to parse: %w", err)
+	}
+
+	if existing, err := sopsfile.Read(target); err == nil {
+		profile, err := resolveProfileForFile(target)
+		if err != nil {
+			return err
+		}
+		if current, err := decryptTree(target); err == nil && reflect.DeepEqual(current, tree) {
+			w.log.Printf("  %s unchanged", target)
+			return nil
+		}
+		runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+		if err := runner.EncryptFor(path, existing.Metadata, encryptOptions(target)); err != nil {
+			return err
+		}
+		w.log.Printf("✓ Encrypted %s -> %s (existing recipients)", path, target)
+		return nil
+	}
+
+	profile, err := w.profileFor(target)
+	if err != nil {
+		return err
+	}
+	if profile == nil {
+		runner := sops.NewRunner(cfg.Settings.SOPSPath, nil)
+		if err := runner.EncryptAs(path, target, sops.Options{Output: target}); err != nil {
+			return err
+		}
+		w.log.Printf("✓ Encrypted %s -> %s (.sops.yaml)", path, target)
+		return nil
+	}
+
+	runner := sops.NewRunner(cfg.Settings.SOPSPath, profile)
+	if err := runner.Encrypt(path, encryptOptions(target)); err != nil {
+		return err
+	}
+	w.log.Printf("✓ Encrypted %s -> %s (profile %s)", path, target, profile.Name)
+	return nil
+}
+
+// encryptOptions writes the encrypted copy to target, and has sops match creation rules
+// against target rather than the plaintext working copy.
+func encryptOptions(target string) sops.Options {
+	return sops.Options{Output: target, Extra: []string{"--filename-override", target}}
+}
+
+// profileFor picks the profile for a new encrypted file. It returns nil when a .sops.yaml
+// creation rule should decide instead.
+func (w *watcher) profileFor(target string) (*config.Profile, error) {
+	if profileName != "" {
+		return resolveProfile()
+	}
+
+	if p := boundProfile(target, w.root); p != nil {
+		return p, nil
+	}
+
+	if rulesPath, err := sopsconfig.Find(filepath.Dir(target)); err == nil {
+		c, ok := w.rules[rulesPath]
+		if !ok {
+			if c, err = sopsconfig.Load(rulesPath); err != nil {
+				return nil, err
+			}
+			w.rules[rulesPath] = c
+		}
+		idx, err := c.Match(target)
+		if err != nil {
+			return nil, err
+		}
+		if idx >= 0 {
+			return nil, nil
+		}
+	}
+
+	return resolveProfile()
+}
+
+func init() {
+	watchCmd.Flags().String("pattern", "*.dec.*", "glob pattern of plaintext copies (matched against the file name)")
+	watchCmd.Flags().String("marker", ".dec", "part of a plaintext copy's name removed to get the encrypted file")
+	watchCmd.Flags().Duration("interval", 500*time.Millisecond, "how often to check for changes")
+	watchCmd.Flags().Duration("debounce", time.Second, "how long a file must be unchanged before it is encrypted")
+}
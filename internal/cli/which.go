@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+var whichProfileCmd = &cobra.Command{
+	Use:   "which-profile <file>",
+	Short: "Show which profiles can decrypt a file",
+	Long: `Read the recipients from an encrypted file's sops metadata and list the
+profiles whose keys match. The sops binary is not needed.
+
+Examples:
+  sopsy which-profile prod/secrets.yaml`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		meta, err := sopsfile.ReadMetadata(args[0])
+		if err != nil {
+			return err
+		}
+
+		matches := matchingProfiles(meta)
+		if len(matches) == 0 {
+			return fmt.Errorf("none of your profiles can decrypt this")
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "NAME\tDESCRIPTION\tDEFAULT")
+		for _, p := range matches {
+			def := ""
+			if p.Name == cfg.DefaultProfile {
+				def = "*"
+			}
+			_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", p.Name, p.Description, def)
+		}
+		_ = w.Flush()
+
+		return nil
+	},
+}
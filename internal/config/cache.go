@@ -0,0 +1,103 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ActivationCachePath returns the path of the compiled activation cache for a config file.
+func ActivationCachePath(configPath string) string {
+	return configPath + ".activation"
+}
+
+// ActivationScript returns the shell export statements for the given profile.
+func (p *Profile) ActivationScript() string {
+	var b strings.Builder
+	if p.Age != nil && p.Age.KeyFile != "" {
+		fmt.Fprintf(&b, "export SOPS_AGE_KEY_FILE=\"%s\"\n", p.Age.GetKeyFilePath())
+	}
+	return b.String()
+}
+
+// ActivationScript returns the shell export statements for the default profile.
+// It is empty when no default profile is set or the profile does not exist.
+func (c *Config) ActivationScript() string {
+	if c.DefaultProfile == "" {
+		return ""
+	}
+	profile, ok := c.Profiles[c.DefaultProfile]
+	if !ok {
+		return ""
+	}
+	return profile.ActivationScript()
+}
+
+// activationStampPrefix starts the first line of the cache, which holds the hash of the
+// config it was compiled from.
+const activationStampPrefix = "# config sha256:"
+
+// activationStamp returns the first line of a cache compiled from the config at configPath.
+func activationStamp(configPath string) (string, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return activationStampPrefix + hex.EncodeToString(sum[:]) + "\n", nil
+}
+
+// ReadActivationCache returns the cached activation script for a config file.
+// The cache is only used when it was compiled from the config's current contents, so
+// manual edits to the config invalidate it regardless of timestamps.
+func ReadActivationCache(configPath string) (string, bool) {
+	stamp, err := activationStamp(configPath)
+	if err != nil {
+		return "", false
+	}
+	data, err := os.ReadFile(ActivationCachePath(configPath))
+	if err != nil {
+		return "", false
+	}
+	script, ok := strings.CutPrefix(string(data), stamp)
+	if !ok {
+		return "", false
+	}
+	return script, true
+}
+
+// WriteActivationCache compiles the activation script and replaces the cache atomically.
+// The cache starts with the hash of the config, which ReadActivationCache checks.
+func WriteActivationCache(cfg *Config, configPath string) error {
+	stamp, err := activationStamp(configPath)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(ActivationCachePath(configPath), []byte(stamp+cfg.ActivationScript()), 0644)
+}
+
+// writeFileAtomic writes data to a temporary file and renames it over path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer func() { _ = os.Remove(tmpPath) }()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// benchConfig writes a config with many profiles, similar to a large team setup.
+func benchConfig(b *testing.B) string {
+	b.Helper()
+
+	cfg := NewConfig()
+	for i := 0; i < 200; i++ {
+		name := fmt.Sprintf("profile-%03d", i)
+		cfg.Profiles[name] = &Profile{
+			Name:        name,
+			Description: "benchmark profile",
+			Age: &AgeConfig{
+				KeyFile:    fmt.Sprintf("~/.config/sops/age/%s.txt", name),
+				Recipients: []string{"age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"},
+			},
+			SOPS: SOPSOptions{EncryptedRegex: "^(data|stringData)$"},
+		}
+	}
+	cfg.DefaultProfile = "profile-100"
+
+	path := filepath.Join(b.TempDir(), "config.yaml")
+	if err := Save(cfg, path); err != nil {
+		b.Fatal(err)
+	}
+	return path
+}
+
+func BenchmarkProfileCurrentFullLoad(b *testing.B) {
+	path := benchConfig(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		cfg, err := Load(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if cfg.ActivationScript() == "" {
+			b.Fatal("empty activation script")
+		}
+	}
+}
+
+func BenchmarkProfileCurrentCached(b *testing.B) {
+	path := benchConfig(b)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		script, ok := ReadActivationCache(path)
+		if !ok || script == "" {
+			b.Fatal("activation cache miss")
+		}
+	}
+}
+
+func TestReadActivationCache(t *testing.T) {
+	cfg := NewConfig()
+	cfg.Profiles["dev"] = &Profile{Name: "dev", Age: &AgeConfig{KeyFile: "/keys/dev.txt"}}
+	cfg.DefaultProfile = "dev"
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := Save(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	want := "export SOPS_AGE_KEY_FILE=\"/keys/dev.txt\"\n"
+	if script, ok := ReadActivationCache(path); !ok || script != want {
+		t.Fatalf("ReadActivationCache() after Save = %q, %v, want %q, true", script, ok, want)
+	}
+
+	// Timestamps alone neither invalidate the cache nor keep it valid
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if script, ok := ReadActivationCache(path); !ok || script != want {
+		t.Errorf("ReadActivationCache() after touching the config = %q, %v, want %q, true", script, ok, want)
+	}
+
+	// An edit with the same size and timestamp invalidates the cache
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited := bytes.Replace(data, []byte("/keys/dev.txt"), []byte("/keys/xyz.txt"), 1)
+	if err := os.WriteFile(path, edited, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, past, past); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ReadActivationCache(path); ok {
+		t.Fatal("ReadActivationCache() hit after the config changed")
+	}
+
+	// A cache without a stamp is never used
+	if err := os.WriteFile(ActivationCachePath(path), []byte(want), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ReadActivationCache(path); ok {
+		t.Fatal("ReadActivationCache() hit without a stamp")
+	}
+
+	// The next Save rebuilds it
+	cfg.Profiles["prod"] = &Profile{Name: "prod", Age: &AgeConfig{KeyFile: "/keys/prod.txt"}}
+	cfg.DefaultProfile = "prod"
+	if err := Save(cfg, path); err != nil {
+		t.Fatal(err)
+	}
+	want = "export SOPS_AGE_KEY_FILE=\"/keys/prod.txt\"\n"
+	if script, ok := ReadActivationCache(path); !ok || script != want {
+		t.Errorf("ReadActivationCache() after Save = %q, %v, want %q, true", script, ok, want)
+	}
+}
+
+func TestReadActivationCacheMissing(t *testing.T) {
+	dir := t.TempDir()
+	if _, ok := ReadActivationCache(filepath.Join(dir, "missing.yaml")); ok {
+		t.Error("ReadActivationCache() hit without a config")
+	}
+
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte("profiles: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ReadActivationCache(path); ok {
+		t.Error("ReadActivationCache() hit without a cache")
+	}
+}
@@ -14,13 +14,15 @@ type Config struct {
 	Version        string              `yaml:"version"`
 	DefaultProfile string              `yaml:"default_profile,omitempty"`
 	Profiles       map[string]*Profile `yaml:"profiles,omitempty"`
+	People         map[string]*Person  `yaml:"people,omitempty"`
 	Settings       Settings            `yaml:"settings,omitempty"`
 }
 
 // Settings contains global sopsy settings.
 type Settings struct {
-	FZFOptions string `yaml:"fzf_options,omitempty"`
-	SOPSPath   string `yaml:"sops_path,omitempty"`
+	FZFOptions    string `yaml:"fzf_options,omitempty"`
+	SOPSPath      string `yaml:"sops_path,omitempty"`
+	AgeKeygenPath string `yaml:"age_keygen_path,omitempty"`
 }
 
 // DefaultConfigPath returns the default configuration file path.
@@ -37,6 +39,7 @@ func NewConfig() *Config {
 	return &Config{
 		Version:  "1",
 		Profiles: make(map[string]*Profile),
+		People:   make(map[string]*Person),
 		Settings: Settings{
 			SOPSPath: "sops",
 		},
@@ -62,6 +65,7 @@ func Load(path string) (*Config, error) {
 	for name, profile := range cfg.Profiles {
 		profile.Name = name
 	}
+	cfg.bindPeople()
 
 	return cfg, nil
 }
@@ -83,6 +87,10 @@ func Save(cfg *Config, path string) error {
 		return fmt.Errorf("failed to write config: %w", err)
 	}
 
+	if err := WriteActivationCache(cfg, path); err != nil {
+		return fmt.Errorf("failed to write activation cache: %w", err)
+	}
+
 	return nil
 }
 
@@ -104,6 +112,7 @@ func (c *Config) AddProfile(profile *Profile) error {
 		return fmt.Errorf("profile already exists: %s", profile.Name)
 	}
 	c.Profiles[profile.Name] = profile
+	c.bindPeople()
 	return nil
 }
 
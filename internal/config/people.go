@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Person is an entry of the team address book. Profiles reference people by name in
+// age.members instead of listing their keys.
+type Person struct {
+	Name  string   `yaml:"-"` // Populated from map key
+	Email string   `yaml:"email,omitempty"`
+	Age   []string `yaml:"age,omitempty"`
+	// SSH are ssh-ed25519 or ssh-rsa public keys, which age and sops can encrypt to
+	SSH []string `yaml:"ssh,omitempty"`
+	// PGP are key fingerprints. They identify the person in file metadata only.
+	PGP []string `yaml:"pgp,omitempty"`
+}
+
+// Recipients returns the public keys files are encrypted for: the age and ssh keys.
+func (p *Person) Recipients() []string {
+	keys := make([]string, 0, len(p.Age)+len(p.SSH))
+	keys = append(keys, p.Age...)
+	return append(keys, p.SSH...)
+}
+
+// Keys returns every key of the person, including pgp fingerprints.
+func (p *Person) Keys() []string {
+	return append(p.Recipients(), p.PGP...)
+}
+
+// GetPerson returns a person of the address book by name.
+func (c *Config) GetPerson(name string) (*Person, error) {
+	person, ok := c.People[name]
+	if !ok {
+		return nil, fmt.Errorf("person not found: %s", name)
+	}
+	return person, nil
+}
+
+// ListPeople returns the address book sorted by name.
+func (c *Config) ListPeople() []*Person {
+	people := make([]*Person, 0, len(c.People))
+	for _, person := range c.People {
+		people = append(people, person)
+	}
+	sort.Slice(people, func(i, j int) bool {
+		return people[i].Name < people[j].Name
+	})
+	return people
+}
+
+// KeyOwners maps every key of the address book to the name of its person.
+func (c *Config) KeyOwners() map[string]string {
+	owners := make(map[string]string)
+	for _, person := range c.ListPeople() {
+		for _, k := range person.Keys() {
+			if _, ok := owners[k]; !ok {
+				owners[k] = person.Name
+			}
+		}
+	}
+	return owners
+}
+
+// bindPeople gives the age config of every profile access to the address book, so that
+// members resolve to keys.
+func (c *Config) bindPeople() {
+	if c.People == nil {
+		c.People = make(map[string]*Person)
+	}
+	for name, person := range c.People {
+		person.Name = name
+	}
+	for _, profile := range c.Profiles {
+		if profile.Age != nil {
+			profile.Age.people = c.People
+		}
+	}
+}
+
+// AddMember makes the named person a member of the profile. It returns false when the
+// person already is one.
+func (c *Config) AddMember(profile *Profile, name string) bool {
+	if profile.Age == nil {
+		profile.Age = &AgeConfig{}
+	}
+	c.bindPeople()
+	for _, m := range profile.Age.Members {
+		if m == name {
+			return false
+		}
+	}
+	profile.Age.Members = append(profile.Age.Members, name)
+	return true
+}
@@ -5,7 +5,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
+
+	"github.com/enbiyagoral/sopsy/internal/sopsconfig"
 )
 
 // Profile represents a SOPS encryption profile.
@@ -18,6 +22,13 @@ type Profile struct {
 
 	// SOPS-specific options
 	SOPS SOPSOptions `yaml:"sops,omitempty"`
+
+	// Paths are glob patterns of the files encrypted with this profile, relative to the
+	// repository root. Patterns without a slash match the file name in any directory.
+	Paths []string `yaml:"paths,omitempty"`
+
+	// RotationInterval is how long secrets of this profile may go unchanged, e.g. "90d"
+	RotationInterval string `yaml:"rotation_interval,omitempty"`
 }
 
 // AgeConfig represents age encryption configuration.
@@ -26,6 +37,10 @@ type AgeConfig struct {
 	KeyFile string `yaml:"key_file,omitempty"`
 	// Recipients are explicit public keys (alternative to KeyFile)
 	Recipients []string `yaml:"recipients,omitempty"`
+	// Members are names of people in the address book whose keys are recipients
+	Members []string `yaml:"members,omitempty"`
+
+	people map[string]*Person
 }
 
 // SOPSOptions represents SOPS-specific encryption options.
@@ -38,7 +53,7 @@ type SOPSOptions struct {
 
 // GetBackendSummary returns a human-readable summary of configured backends.
 func (p *Profile) GetBackendSummary() string {
-	if p.Age != nil && (p.Age.KeyFile != "" || len(p.Age.Recipients) > 0) {
+	if p.HasBackends() {
 		return "age"
 	}
 	return "none"
@@ -46,7 +61,50 @@ func (p *Profile) GetBackendSummary() string {
 
 // HasBackends returns true if the profile has at least one backend configured.
 func (p *Profile) HasBackends() bool {
-	return p.Age != nil && (p.Age.KeyFile != "" || len(p.Age.Recipients) > 0)
+	return p.Age != nil && (p.Age.KeyFile != "" || len(p.Age.Recipients) > 0 || len(p.Age.Members) > 0)
+}
+
+// MatchesRecipients returns true if the profile's key file holds the identity of one of
+// recipients, so the profile can decrypt a file encrypted for them. Explicit recipients
+// and members are public keys of others and do not count.
+func (p *Profile) MatchesRecipients(recipients []string) bool {
+	if p.Age == nil || p.Age.KeyFile == "" {
+		return false
+	}
+	keys, err := PublicKeysInFile(p.Age.KeyFile)
+	if err != nil {
+		return false
+	}
+	for _, k := range keys {
+		if containsString(recipients, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchesPath returns true if rel, a slash-separated path relative to the repository root,
+// matches one of the profile's path patterns. Patterns are matched like the path_regex
+// generated from them.
+func (p *Profile) MatchesPath(rel string) bool {
+	for _, pattern := range p.Paths {
+		if ok, _ := regexp.MatchString(sopsconfig.GlobRegex(pattern), rel); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GetRotationInterval returns the parsed rotation interval, or 0 when none is set.
+func (p *Profile) GetRotationInterval() (time.Duration, error) {
+	if p.RotationInterval == "" {
+		return 0, nil
+	}
+	d, err := ParseInterval(p.RotationInterval)
+	if err != nil {
+		return 0, fmt.Errorf("profile '%s': invalid rotation_interval: %w", p.Name, err)
+	}
+	return d, nil
 }
 
 // GetPublicKey extracts the public key from an age key file or returns recipients.
@@ -54,11 +112,26 @@ func (a *AgeConfig) GetPublicKey() (string, error) {
 	if len(a.Recipients) > 0 {
 		return a.Recipients[0], nil
 	}
+	if len(a.Members) > 0 && a.KeyFile == "" {
+		keys, err := a.memberKeys()
+		if err != nil {
+			return "", err
+		}
+		if len(keys) == 0 {
+			return "", fmt.Errorf("members have no age or ssh keys")
+		}
+		return keys[0], nil
+	}
 
 	if a.KeyFile == "" {
 		return "", fmt.Errorf("no key_file or recipients configured")
 	}
 
+	return a.keyFilePublicKey()
+}
+
+// keyFilePublicKey reads the public key from the "# public key:" comment of the key file.
+func (a *AgeConfig) keyFilePublicKey() (string, error) {
 	keyFile := expandPath(a.KeyFile)
 	file, err := os.Open(keyFile)
 	if err != nil {
@@ -81,28 +154,50 @@ func (a *AgeConfig) GetPublicKey() (string, error) {
 	return "", fmt.Errorf("no public key found in key file: %s", keyFile)
 }
 
-// GetAllPublicKeys returns all public keys (from file and recipients).
+// PublicKeysInFile returns the public keys of every identity in an age key file, read
+// from their "# public key:" comments.
+func PublicKeysInFile(path string) ([]string, error) {
+	file, err := os.Open(expandPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var keys []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if key, ok := strings.CutPrefix(scanner.Text(), "# public key:"); ok {
+			keys = append(keys, strings.TrimSpace(key))
+		}
+	}
+	return keys, scanner.Err()
+}
+
+// GetAllPublicKeys returns all public keys (from recipients, members and file).
 func (a *AgeConfig) GetAllPublicKeys() ([]string, error) {
 	var keys []string
 
 	// Add recipients first
 	keys = append(keys, a.Recipients...)
 
+	members, err := a.memberKeys()
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range members {
+		if !containsString(keys, key) {
+			keys = append(keys, key)
+		}
+	}
+
 	// Add key from file if specified
 	if a.KeyFile != "" {
-		key, err := a.GetPublicKey()
+		key, err := a.keyFilePublicKey()
 		if err != nil {
 			return nil, err
 		}
 		// Avoid duplicates
-		found := false
-		for _, k := range keys {
-			if k == key {
-				found = true
-				break
-			}
-		}
-		if !found {
+		if !containsString(keys, key) {
 			keys = append(keys, key)
 		}
 	}
@@ -110,6 +205,28 @@ func (a *AgeConfig) GetAllPublicKeys() ([]string, error) {
 	return keys, nil
 }
 
+// memberKeys resolves the members to the recipients of their address book entries.
+func (a *AgeConfig) memberKeys() ([]string, error) {
+	var keys []string
+	for _, name := range a.Members {
+		person, ok := a.people[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown member '%s': not in the people address book", name)
+		}
+		keys = append(keys, person.Recipients()...)
+	}
+	return keys, nil
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
 // GetKeyFilePath returns the expanded key file path.
 func (a *AgeConfig) GetKeyFilePath() string {
 	if a.KeyFile == "" {
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Key rotation phases, in order.
+const (
+	RotationGenerate = "generate"
+	RotationAdd      = "add"
+	RotationSwitch   = "switch"
+	RotationConfirm  = "confirm"
+	RotationRemove   = "remove"
+	RotationArchive  = "archive"
+)
+
+// RotationState records the progress of a key rotation so it can resume after a failure.
+type RotationState struct {
+	Profile      string    `json:"profile"`
+	Phase        string    `json:"phase"`
+	Dir          string    `json:"dir"`
+	StartedAt    time.Time `json:"started_at"`
+	OldKeyFile   string    `json:"old_key_file"`
+	OldPublicKey string    `json:"old_public_key"`
+	NewKeyFile   string    `json:"new_key_file"`
+	// NewPublicKey is empty until the generate phase has created NewKeyFile
+	NewPublicKey string `json:"new_public_key"`
+	// Files are the encrypted files being rotated, fixed when the rotation starts
+	Files []string `json:"files"`
+	// Done holds the files that completed the current phase
+	Done map[string]bool `json:"done,omitempty"`
+}
+
+// RotationStatePath returns the path of the rotation state of a profile for a config file.
+func RotationStatePath(configPath, profile string) string {
+	return configPath + ".rotate-" + profile + ".json"
+}
+
+// LoadRotationState reads a rotation state. It returns nil without error when there is none.
+func LoadRotationState(path string) (*RotationState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read rotation state: %w", err)
+	}
+
+	var state RotationState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse rotation state: %w", err)
+	}
+	if state.Done == nil {
+		state.Done = make(map[string]bool)
+	}
+	return &state, nil
+}
+
+// Save replaces the rotation state atomically.
+func (s *RotationState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal rotation state: %w", err)
+	}
+	if err := writeFileAtomic(path, append(data, '\n'), 0600); err != nil {
+		return fmt.Errorf("failed to write rotation state: %w", err)
+	}
+	return nil
+}
+
+// intervalUnits are the units ParseInterval accepts on top of time.ParseDuration.
+var intervalUnits = map[string]time.Duration{
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+	"y": 365 * 24 * time.Hour,
+}
+
+// ParseInterval parses a rotation interval in days, weeks or years ("180d", "12w", "1y"),
+// or any duration time.ParseDuration accepts ("720h").
+func ParseInterval(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s != "" {
+		if unit, ok := intervalUnits[s[len(s)-1:]]; ok {
+			n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid interval %q", s)
+			}
+			if n > math.MaxInt64/int64(unit) {
+				return 0, fmt.Errorf("interval %q is too long", s)
+			}
+			return time.Duration(n) * unit, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid interval %q, use e.g. 180d, 12w, 1y or 720h", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseInterval(t *testing.T) {
+	const day = 24 * time.Hour
+
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{in: "180d", want: 180 * day},
+		{in: "12w", want: 12 * 7 * day},
+		{in: "1y", want: 365 * day},
+		{in: " 90d ", want: 90 * day},
+		{in: "720h", want: 720 * time.Hour},
+		{in: "1h30m", want: 90 * time.Minute},
+		{in: "106751d", want: 106751 * day},
+		{in: "", wantErr: true},
+		{in: "d", wantErr: true},
+		{in: "0d", wantErr: true},
+		{in: "0", wantErr: true},
+		{in: "0h", wantErr: true},
+		{in: "-5d", wantErr: true},
+		{in: "-1h", wantErr: true},
+		{in: "1.5d", wantErr: true},
+		{in: "10x", wantErr: true},
+		{in: "106752d", wantErr: true},
+		{in: "15251w", wantErr: true},
+		{in: "293y", wantErr: true},
+		{in: "99999999999999999999d", wantErr: true},
+		{in: "9999999999h", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseInterval(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInterval(%q) error = %v, wantErr %v", tt.in, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseInterval(%q) = %v, want %v", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRotationInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{interval: "", want: 0},
+		{interval: "90d", want: 90 * 24 * time.Hour},
+		{interval: "2w", want: 14 * 24 * time.Hour},
+		{interval: "1y", want: 365 * 24 * time.Hour},
+		{interval: "48h", want: 48 * time.Hour},
+		{interval: "0d", wantErr: true},
+		{interval: "-1w", wantErr: true},
+		{interval: "1000y", wantErr: true},
+		{interval: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.interval, func(t *testing.T) {
+			p := &Profile{Name: "prod", RotationInterval: tt.interval}
+			got, err := p.GetRotationInterval()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetRotationInterval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("GetRotationInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
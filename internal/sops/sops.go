@@ -0,0 +1,272 @@
+// Package sops runs the sops binary with the settings of a sopsy profile.
+package sops
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/enbiyagoral/sopsy/internal/config"
+	"github.com/enbiyagoral/sopsy/internal/sopsfile"
+)
+
+// DefaultPath is the sops binary used when none is configured.
+const DefaultPath = "sops"
+
+// Options controls where sops writes its result and which extra arguments it receives.
+type Options struct {
+	// InPlace writes the result back to the input file.
+	InPlace bool
+	// Output writes the result to the given path instead of stdout.
+	Output string
+	// Extra arguments are passed to sops verbatim, before the file name.
+	Extra []string
+}
+
+// Runner invokes sops on behalf of a profile.
+type Runner struct {
+	Path    string
+	Profile *config.Profile
+
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// NewRunner creates a runner for the given sops binary and profile, wired to the standard streams.
+func NewRunner(path string, profile *config.Profile) *Runner {
+	if path == "" {
+		path = DefaultPath
+	}
+	return &Runner{
+		Path:    path,
+		Profile: profile,
+		Stdin:   os.Stdin,
+		Stdout:  os.Stdout,
+		Stderr:  os.Stderr,
+	}
+}
+
+// Encrypt encrypts file for the profile's recipients.
+func (r *Runner) Encrypt(file string, opts Options) error {
+	flags, err := EncryptFlags(r.Profile)
+	if err != nil {
+		return err
+	}
+	return r.run("encrypt", file, flags, opts)
+}
+
+// EncryptFor encrypts file for the master keys and encryption rules recorded in meta,
+// e.g. to re-encrypt a file for its original recipients.
+func (r *Runner) EncryptFor(file string, meta *sopsfile.Metadata, opts Options) error {
+	flags, err := MetadataFlags(meta)
+	if err != nil {
+		return err
+	}
+	return r.run("encrypt", file, flags, opts)
+}
+
+// EncryptAs encrypts file with the .sops.yaml creation rule that matches target instead
+// of file, e.g. for a plaintext copy encrypted into target.
+func (r *Runner) EncryptAs(file, target string, opts Options) error {
+	return r.run("encrypt", file, []string{"--filename-override", target}, opts)
+}
+
+// UpdateKeys replaces the master keys of file with those of the creation rule in the sops
+// config at configPath that matches it, without re-encrypting the values.
+func (r *Runner) UpdateKeys(file, configPath string) error {
+	c := r.Command("--config", configPath, "updatekeys", "--yes", file)
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("sops updatekeys failed: %w", err)
+	}
+	return nil
+}
+
+// Decrypt decrypts file with the profile's identity.
+func (r *Runner) Decrypt(file string, opts Options) error {
+	return r.run("decrypt", file, nil, opts)
+}
+
+// Edit opens file in the sops editor. New files are created for the profile's recipients.
+func (r *Runner) Edit(file string, opts Options) error {
+	flags, err := EncryptFlags(r.Profile)
+	if err != nil {
+		return err
+	}
+	return r.run("edit", file, flags, opts)
+}
+
+// Rotate generates a new data key for file and re-encrypts it.
+func (r *Runner) Rotate(file string, opts Options) error {
+	return r.run("rotate", file, nil, opts)
+}
+
+// DecryptBytes decrypts file and returns the plaintext document in the file's format.
+func (r *Runner) DecryptBytes(file string) ([]byte, error) {
+	var out bytes.Buffer
+	c := r.Command("decrypt", file)
+	c.Stdout = &out
+	if err := c.Run(); err != nil {
+		return nil, fmt.Errorf("sops decrypt failed: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// Extract decrypts file and prints only the value at index (sops index syntax, e.g. ["a"][0]).
+func (r *Runner) Extract(file, index string) error {
+	return r.run("decrypt", file, []string{"--extract", index}, Options{})
+}
+
+// Set replaces the value at index with the JSON-encoded value. The value is passed
+// on stdin so it never appears in the process list.
+func (r *Runner) Set(file, index string, value []byte) error {
+	c := r.Command("set", "--value-stdin", file, index)
+	c.Stdin = bytes.NewReader(value)
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("sops set failed: %w", err)
+	}
+	return nil
+}
+
+func (r *Runner) run(subcommand, file string, flags []string, opts Options) error {
+	args := []string{subcommand}
+	args = append(args, flags...)
+	if opts.InPlace {
+		args = append(args, "--in-place")
+	}
+	if opts.Output != "" {
+		args = append(args, "--output", opts.Output)
+	}
+	args = append(args, opts.Extra...)
+	args = append(args, file)
+
+	c := r.Command(args...)
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("sops %s failed: %w", subcommand, err)
+	}
+	return nil
+}
+
+// Command builds an exec.Cmd for sops with the profile's identity in the environment.
+func (r *Runner) Command(args ...string) *exec.Cmd {
+	c := exec.Command(r.Path, args...)
+	c.Env = Env(r.Profile)
+	c.Stdin = r.Stdin
+	c.Stdout = r.Stdout
+	c.Stderr = r.Stderr
+	return c
+}
+
+// Env returns the current environment with SOPS_AGE_KEY_FILE pointing at the profile's key file.
+func Env(profile *config.Profile) []string {
+	env := os.Environ()
+	if profile == nil || profile.Age == nil || profile.Age.KeyFile == "" {
+		return env
+	}
+	filtered := env[:0:0]
+	for _, kv := range env {
+		if !strings.HasPrefix(kv, "SOPS_AGE_KEY_FILE=") {
+			filtered = append(filtered, kv)
+		}
+	}
+	return append(filtered, "SOPS_AGE_KEY_FILE="+profile.Age.GetKeyFilePath())
+}
+
+// EncryptFlags translates the profile's recipients and SOPS options into sops flags.
+func EncryptFlags(profile *config.Profile) ([]string, error) {
+	if profile == nil || !profile.HasBackends() {
+		return nil, fmt.Errorf("profile has no encryption backend configured")
+	}
+
+	keys, err := profile.Age.GetAllPublicKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	flags := []string{"--age", strings.Join(keys, ",")}
+	flags = append(flags, ruleFlags(ProfileRules(profile))...)
+
+	return flags, nil
+}
+
+// MetadataFlags translates the master keys and encryption rules of existing sops metadata
+// into sops flags. Files using key_groups cannot be expressed as flags.
+func MetadataFlags(meta *sopsfile.Metadata) ([]string, error) {
+	if len(meta.KeyGroups) > 0 {
+		return nil, fmt.Errorf("files with key_groups can only be encrypted through .sops.yaml creation rules")
+	}
+
+	var flags []string
+	recipients := meta.Recipients()
+	for _, f := range []struct{ backend, flag string }{
+		{sopsfile.BackendAge, "--age"},
+		{sopsfile.BackendPGP, "--pgp"},
+		{sopsfile.BackendGCPKMS, "--gcp-kms"},
+		{sopsfile.BackendAzureKV, "--azure-kv"},
+		{sopsfile.BackendHCVault, "--hc-vault-transit"},
+	} {
+		if ids := recipients[f.backend]; len(ids) > 0 {
+			flags = append(flags, f.flag, strings.Join(ids, ","))
+		}
+	}
+
+	var kms []string
+	var context []string
+	for _, k := range meta.KMS {
+		arn := k.ARN
+		if k.Role != "" {
+			arn += "+" + k.Role
+		}
+		kms = append(kms, arn)
+		for key, v := range k.Context {
+			context = append(context, key+":"+v)
+		}
+		if k.AWSProfile != "" {
+			flags = append(flags, "--aws-profile", k.AWSProfile)
+		}
+	}
+	if len(kms) > 0 {
+		flags = append(flags, "--kms", strings.Join(kms, ","))
+	}
+	if len(context) > 0 {
+		sort.Strings(context)
+		flags = append(flags, "--encryption-context", strings.Join(context, ","))
+	}
+
+	if len(flags) == 0 {
+		return nil, fmt.Errorf("metadata has no master keys")
+	}
+	return append(flags, ruleFlags(meta.Rules())...), nil
+}
+
+// ProfileRules returns the encryption rules of a profile.
+func ProfileRules(p *config.Profile) sopsfile.Rules {
+	return sopsfile.Rules{
+		EncryptedRegex:    p.SOPS.EncryptedRegex,
+		UnencryptedRegex:  p.SOPS.UnencryptedRegex,
+		EncryptedSuffix:   p.SOPS.EncryptedSuffix,
+		UnencryptedSuffix: p.SOPS.UnencryptedSuffix,
+	}
+}
+
+// ruleFlags translates encryption rules into sops flags.
+func ruleFlags(rules sopsfile.Rules) []string {
+	var flags []string
+	if rules.EncryptedRegex != "" {
+		flags = append(flags, "--encrypted-regex", rules.EncryptedRegex)
+	}
+	if rules.EncryptedSuffix != "" {
+		flags = append(flags, "--encrypted-suffix", rules.EncryptedSuffix)
+	}
+	if rules.UnencryptedRegex != "" {
+		flags = append(flags, "--unencrypted-regex", rules.UnencryptedRegex)
+	}
+	if rules.UnencryptedSuffix != "" {
+		flags = append(flags, "--unencrypted-suffix", rules.UnencryptedSuffix)
+	}
+	return flags
+}
@@ -0,0 +1,204 @@
+package sopsconfig
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// generatedMarker starts the comment that marks a creation rule generated from a profile.
+const generatedMarker = "# sopsy profile: "
+
+// ManagedRule is a creation rule generated from a sopsy profile.
+type ManagedRule struct {
+	Profile string
+	Rule    CreationRule
+}
+
+// Update sets the managed rules in the .sops.yaml document data, keeping other rules and
+// comments. Rules generated before are replaced in place; new ones are inserted before the
+// first rule without path_regex, which would otherwise shadow them. It returns the updated
+// document and the profiles whose rules changed.
+func Update(data []byte, rules []ManagedRule) ([]byte, []string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("expected a mapping at the top level")
+	}
+
+	seq := mappingValue(root, "creation_rules")
+	if seq == nil {
+		seq = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "creation_rules"}, seq)
+	}
+	if seq.Kind != yaml.SequenceNode {
+		return nil, nil, fmt.Errorf("creation_rules must be a list")
+	}
+
+	var changed []string
+	for _, m := range rules {
+		node := &yaml.Node{}
+		if err := node.Encode(m.Rule); err != nil {
+			return nil, nil, err
+		}
+		node.HeadComment = generatedMarker + m.Profile
+
+		if i := managedRuleIndex(seq, m.Profile); i >= 0 {
+			var current CreationRule
+			if err := seq.Content[i].Decode(&current); err != nil {
+				return nil, nil, fmt.Errorf("rule of profile %s: %w", m.Profile, err)
+			}
+			if reflect.DeepEqual(current, m.Rule) {
+				continue
+			}
+			seq.Content[i] = node
+		} else {
+			at := catchAllIndex(seq)
+			seq.Content = append(seq.Content[:at], append([]*yaml.Node{node}, seq.Content[at:]...)...)
+		}
+		changed = append(changed, m.Profile)
+	}
+
+	if len(changed) == 0 {
+		return data, nil, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return nil, nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), changed, nil
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// managedRuleIndex returns the position of the rule generated for profile, or -1.
+func managedRuleIndex(seq *yaml.Node, profile string) int {
+	for i, n := range seq.Content {
+		if managedProfile(n) == profile {
+			return i
+		}
+	}
+	return -1
+}
+
+// managedProfile returns the profile a rule node was generated from, or "".
+func managedProfile(rule *yaml.Node) string {
+	for _, line := range strings.Split(rule.HeadComment, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), generatedMarker); ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// catchAllIndex returns the position of the first rule without path_regex, or the end.
+func catchAllIndex(seq *yaml.Node) int {
+	for i, n := range seq.Content {
+		if v := mappingValue(n, "path_regex"); v == nil || v.Value == "" {
+			return i
+		}
+	}
+	return len(seq.Content)
+}
+
+// GlobRegex converts a sopsy path glob into a path_regex. Patterns without a slash match
+// the file name in any directory, others match the whole path relative to the config.
+// A "**" segment matches any number of directories.
+func GlobRegex(pattern string) string {
+	var b strings.Builder
+	if strings.Contains(pattern, "/") {
+		b.WriteString("^")
+	} else {
+		b.WriteString("(^|/)")
+	}
+
+	pattern = strings.TrimPrefix(pattern, "/")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			if i+1 >= len(pattern) || pattern[i+1] != '*' {
+				b.WriteString("[^/]*")
+				continue
+			}
+			i++
+			if i+1 < len(pattern) && pattern[i+1] == '/' {
+				i++
+				b.WriteString("(.*/)?")
+				continue
+			}
+			b.WriteString(".*")
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			// Character classes use the same syntax, including ^ for negation
+			b.WriteString(pattern[i : i+end+1])
+			i += end
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				b.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String()
+}
+
+// RebaseGlob rebases a path glob relative to the repository root onto dir, the
+// slash-separated directory of the sops config relative to that root, since path_regex
+// is matched relative to the config. It returns false when the pattern matches no file
+// under dir.
+func RebaseGlob(pattern, dir string) (string, bool) {
+	if dir == "" || dir == "." || !strings.Contains(pattern, "/") {
+		return pattern, true
+	}
+
+	rest := strings.Split(strings.TrimPrefix(pattern, "/"), "/")
+	for _, elem := range strings.Split(dir, "/") {
+		if rest[0] == "**" {
+			break
+		}
+		// The last element matches files, not the directories leading to dir
+		if len(rest) < 2 {
+			return "", false
+		}
+		if ok, _ := path.Match(rest[0], elem); !ok {
+			return "", false
+		}
+		rest = rest[1:]
+	}
+	// Keep the leading slash so the pattern stays anchored to the config directory
+	return "/" + strings.Join(rest, "/"), true
+}
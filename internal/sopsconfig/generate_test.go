@@ -0,0 +1,97 @@
+package sopsconfig
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGlobRegex(t *testing.T) {
+	tests := []struct {
+		pattern string
+		match   []string
+		noMatch []string
+	}{
+		{
+			pattern: "*.env",
+			match:   []string{".env", "app.env", "deploy/prod/app.env"},
+			noMatch: []string{"app.env.bak", "env"},
+		},
+		{
+			pattern: "deploy/prod/*.yaml",
+			match:   []string{"deploy/prod/db.yaml"},
+			noMatch: []string{"deploy/prod/a/db.yaml", "x/deploy/prod/db.yaml"},
+		},
+		{
+			pattern: "/deploy/?.yaml",
+			match:   []string{"deploy/a.yaml"},
+			noMatch: []string{"deploy/ab.yaml"},
+		},
+		{
+			pattern: "deploy/**/*.yaml",
+			match:   []string{"deploy/db.yaml", "deploy/prod/db.yaml", "deploy/prod/eu/db.yaml"},
+			noMatch: []string{"other/deploy/db.yaml", "deploy/db.json"},
+		},
+		{
+			pattern: "**/secrets.yaml",
+			match:   []string{"secrets.yaml", "a/secrets.yaml", "a/b/secrets.yaml"},
+			noMatch: []string{"a/mysecrets.yaml"},
+		},
+		{
+			pattern: "deploy/**",
+			match:   []string{"deploy/a", "deploy/a/b.yaml"},
+			noMatch: []string{"deploy", "other/a"},
+		},
+		{
+			pattern: "[ab].env",
+			match:   []string{"a.env", "x/b.env"},
+			noMatch: []string{"c.env"},
+		},
+		{
+			pattern: `\*.env`,
+			match:   []string{"*.env"},
+			noMatch: []string{"a.env"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			re := regexp.MustCompile(GlobRegex(tt.pattern))
+			for _, p := range tt.match {
+				if !re.MatchString(p) {
+					t.Errorf("%s does not match %q", re, p)
+				}
+			}
+			for _, p := range tt.noMatch {
+				if re.MatchString(p) {
+					t.Errorf("%s matches %q", re, p)
+				}
+			}
+		})
+	}
+}
+
+func TestRebaseGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		dir     string
+		want    string
+		wantOk  bool
+	}{
+		{pattern: "deploy/prod/*.yaml", dir: ".", want: "deploy/prod/*.yaml", wantOk: true},
+		{pattern: "*.env", dir: "deploy", want: "*.env", wantOk: true},
+		{pattern: "deploy/prod/*.yaml", dir: "deploy", want: "/prod/*.yaml", wantOk: true},
+		{pattern: "/deploy/prod/*.yaml", dir: "deploy/prod", want: "/*.yaml", wantOk: true},
+		{pattern: "*/prod/*.yaml", dir: "deploy", want: "/prod/*.yaml", wantOk: true},
+		{pattern: "deploy/**/*.yaml", dir: "deploy/prod", want: "/**/*.yaml", wantOk: true},
+		{pattern: "**/*.yaml", dir: "deploy", want: "/**/*.yaml", wantOk: true},
+		{pattern: "other/*.yaml", dir: "deploy"},
+		{pattern: "deploy/*.yaml", dir: "deploy/prod"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" in "+tt.dir, func(t *testing.T) {
+			got, ok := RebaseGlob(tt.pattern, tt.dir)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("RebaseGlob() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,224 @@
+// Package sopsconfig reads .sops.yaml files and matches paths against their creation rules
+// the same way sops does.
+package sopsconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the config file names sops looks for, in order.
+var FileNames = []string{".sops.yaml", ".sops.yml"}
+
+// maxDepth limits how many parent directories are searched, like sops.
+const maxDepth = 100
+
+// Config is a parsed .sops.yaml file.
+type Config struct {
+	// Path is the location the config was loaded from.
+	Path          string         `yaml:"-"`
+	CreationRules []CreationRule `yaml:"creation_rules,omitempty"`
+}
+
+// CreationRule is an entry of creation_rules.
+type CreationRule struct {
+	PathRegex         string     `yaml:"path_regex,omitempty"`
+	Age               KeyList    `yaml:"age,omitempty"`
+	PGP               KeyList    `yaml:"pgp,omitempty"`
+	KMS               KeyList    `yaml:"kms,omitempty"`
+	AWSProfile        string     `yaml:"aws_profile,omitempty"`
+	GCPKMS            KeyList    `yaml:"gcp_kms,omitempty"`
+	AzureKeyVault     KeyList    `yaml:"azure_keyvault,omitempty"`
+	HCVaultTransitURI KeyList    `yaml:"hc_vault_transit_uri,omitempty"`
+	KeyGroups         []KeyGroup `yaml:"key_groups,omitempty"`
+	ShamirThreshold   int        `yaml:"shamir_threshold,omitempty"`
+
+	EncryptedRegex    string `yaml:"encrypted_regex,omitempty"`
+	UnencryptedRegex  string `yaml:"unencrypted_regex,omitempty"`
+	EncryptedSuffix   string `yaml:"encrypted_suffix,omitempty"`
+	UnencryptedSuffix string `yaml:"unencrypted_suffix,omitempty"`
+
+	// Profile is the sopsy profile the rule was generated from, if any.
+	Profile string `yaml:"-"`
+}
+
+// KeyGroup is an entry of key_groups.
+type KeyGroup struct {
+	Age []string `yaml:"age,omitempty"`
+	PGP []string `yaml:"pgp,omitempty"`
+}
+
+// KeyList is a list of keys written either as a comma separated string or a YAML list.
+type KeyList []string
+
+// UnmarshalYAML accepts both "a,b" and [a, b].
+func (k *KeyList) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*k = splitKeys(node.Value)
+		return nil
+	case yaml.SequenceNode:
+		var items []string
+		if err := node.Decode(&items); err != nil {
+			return err
+		}
+		var keys KeyList
+		for _, item := range items {
+			keys = append(keys, splitKeys(item)...)
+		}
+		*k = keys
+		return nil
+	}
+	return fmt.Errorf("line %d: expected a string or a list of keys", node.Line)
+}
+
+// MarshalYAML writes the keys as a comma separated string, the form sops documents.
+func (k KeyList) MarshalYAML() (any, error) {
+	return strings.Join(k, ","), nil
+}
+
+func splitKeys(s string) []string {
+	var keys []string
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Find looks for a sops config file in dir and its parents, like sops does.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for i := 0; i < maxDepth; i++ {
+		for _, name := range FileNames {
+			candidate := filepath.Join(dir, name)
+			if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+				return candidate, nil
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return "", fmt.Errorf("no %s found", FileNames[0])
+}
+
+// Load parses the sops config file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	c := &Config{}
+	if err := doc.Decode(c); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		if seq := mappingValue(doc.Content[0], "creation_rules"); seq != nil {
+			for i, n := range seq.Content {
+				if i < len(c.CreationRules) {
+					c.CreationRules[i].Profile = managedProfile(n)
+				}
+			}
+		}
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	c.Path = abs
+	return c, nil
+}
+
+// RelPath returns the string sops matches path_regex against: the absolute file path
+// with the config directory prefix removed.
+func (c *Config) RelPath(file string) (string, error) {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(abs, filepath.Dir(c.Path)+string(filepath.Separator)), nil
+}
+
+// Match returns the index of the first creation rule that applies to file, or -1.
+// A rule without path_regex matches every file.
+func (c *Config) Match(file string) (int, error) {
+	rel, err := c.RelPath(file)
+	if err != nil {
+		return -1, err
+	}
+	for i, rule := range c.CreationRules {
+		ok, err := rule.Matches(rel)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return -1, nil
+}
+
+// Matches reports whether the rule applies to rel, a path relative to the config directory.
+func (r *CreationRule) Matches(rel string) (bool, error) {
+	if r.PathRegex == "" {
+		return true, nil
+	}
+	re, err := regexp.Compile(r.PathRegex)
+	if err != nil {
+		return false, fmt.Errorf("invalid path_regex %q: %w", r.PathRegex, err)
+	}
+	return re.MatchString(rel), nil
+}
+
+// AgeRecipients returns the age recipients of the rule, including those in key groups.
+func (r *CreationRule) AgeRecipients() []string {
+	recipients := append([]string{}, r.Age...)
+	for _, g := range r.KeyGroups {
+		recipients = append(recipients, g.Age...)
+	}
+	return recipients
+}
+
+// Shadow describes a creation rule that can never match because an earlier rule matches
+// every path it would.
+type Shadow struct {
+	Index  int
+	By     int
+	Reason string
+}
+
+// Shadowed returns the rules made unreachable by an earlier rule without path_regex or
+// with the same path_regex. Overlaps between different regular expressions are not detected.
+func (c *Config) Shadowed() []Shadow {
+	var shadows []Shadow
+	for j, rule := range c.CreationRules {
+		for i := 0; i < j; i++ {
+			earlier := c.CreationRules[i]
+			if earlier.PathRegex == "" {
+				shadows = append(shadows, Shadow{Index: j, By: i, Reason: "it has no path_regex and matches every file"})
+				break
+			}
+			if earlier.PathRegex == rule.PathRegex {
+				shadows = append(shadows, Shadow{Index: j, By: i, Reason: "it has the same path_regex"})
+				break
+			}
+		}
+	}
+	return shadows
+}
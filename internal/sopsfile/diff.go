@@ -0,0 +1,94 @@
+package sopsfile
+
+import "reflect"
+
+// ChangeKind describes how a value differs between two trees.
+type ChangeKind string
+
+// Change kinds.
+const (
+	Added   ChangeKind = "added"
+	Removed ChangeKind = "removed"
+	Changed ChangeKind = "changed"
+)
+
+// Change is a single difference between two trees.
+type Change struct {
+	Kind ChangeKind
+	Path Path
+	Old  any
+	New  any
+}
+
+// Diff compares two trees and returns the added, removed and changed leaves in
+// document order. Lists are compared element by element.
+func Diff(a, b Branch) []Change {
+	var changes []Change
+	diffValues(nil, a, b, &changes)
+	return changes
+}
+
+func diffValues(path Path, a, b any, changes *[]Change) {
+	switch av := a.(type) {
+	case Branch:
+		if bv, ok := b.(Branch); ok {
+			diffBranches(path, av, bv, changes)
+			return
+		}
+	case []any:
+		if bv, ok := b.([]any); ok {
+			diffLists(path, av, bv, changes)
+			return
+		}
+	}
+
+	if !reflect.DeepEqual(a, b) && ScalarString(a) != ScalarString(b) {
+		*changes = append(*changes, Change{Kind: Changed, Path: path, Old: a, New: b})
+	}
+}
+
+func diffBranches(path Path, a, b Branch, changes *[]Change) {
+	for _, item := range a {
+		child := appendPath(path, item.Key)
+		if bv, ok := b.Get(item.Key); ok {
+			diffValues(child, item.Value, bv, changes)
+			continue
+		}
+		*changes = append(*changes, Change{Kind: Removed, Path: child, Old: item.Value})
+	}
+	for _, item := range b {
+		if _, ok := a.Get(item.Key); !ok {
+			*changes = append(*changes, Change{Kind: Added, Path: appendPath(path, item.Key), New: item.Value})
+		}
+	}
+}
+
+func diffLists(path Path, a, b []any, changes *[]Change) {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		child := appendPath(path, i)
+		switch {
+		case i >= len(b):
+			*changes = append(*changes, Change{Kind: Removed, Path: child, Old: a[i]})
+		case i >= len(a):
+			*changes = append(*changes, Change{Kind: Added, Path: child, New: b[i]})
+		default:
+			diffValues(child, a[i], b[i], changes)
+		}
+	}
+}
+
+// appendPath returns a copy of path with elem added, so sibling paths never share storage.
+func appendPath(path Path, elem any) Path {
+	out := make(Path, len(path), len(path)+1)
+	copy(out, path)
+	return append(out, elem)
+}
+
+// IsLeaf returns true if v is a scalar rather than a Branch or list.
+func IsLeaf(v any) bool {
+	switch v.(type) {
+	case Branch, []any:
+		return false
+	}
+	return true
+}
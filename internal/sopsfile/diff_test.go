@@ -0,0 +1,99 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Branch
+		want []Change
+	}{
+		{
+			name: "equal",
+			a:    Branch{{Key: "a", Value: "1"}},
+			b:    Branch{{Key: "a", Value: "1"}},
+		},
+		{
+			name: "changed",
+			a:    Branch{{Key: "db", Value: Branch{{Key: "password", Value: "old"}}}},
+			b:    Branch{{Key: "db", Value: Branch{{Key: "password", Value: "new"}}}},
+			want: []Change{{Kind: Changed, Path: Path{"db", "password"}, Old: "old", New: "new"}},
+		},
+		{
+			name: "added and removed",
+			a:    Branch{{Key: "a", Value: "1"}, {Key: "b", Value: "2"}},
+			b:    Branch{{Key: "a", Value: "1"}, {Key: "c", Value: "3"}},
+			want: []Change{
+				{Kind: Removed, Path: Path{"b"}, Old: "2"},
+				{Kind: Added, Path: Path{"c"}, New: "3"},
+			},
+		},
+		{
+			name: "lists element by element",
+			a:    Branch{{Key: "l", Value: []any{"x", "y", "z"}}},
+			b:    Branch{{Key: "l", Value: []any{"x", "Y"}}},
+			want: []Change{
+				{Kind: Changed, Path: Path{"l", 1}, Old: "y", New: "Y"},
+				{Kind: Removed, Path: Path{"l", 2}, Old: "z"},
+			},
+		},
+		{
+			name: "list grows",
+			a:    Branch{{Key: "l", Value: []any{"x"}}},
+			b:    Branch{{Key: "l", Value: []any{"x", "y"}}},
+			want: []Change{{Kind: Added, Path: Path{"l", 1}, New: "y"}},
+		},
+		{
+			name: "same scalar text across formats",
+			a:    Branch{{Key: "port", Value: 5432}},
+			b:    Branch{{Key: "port", Value: json.Number("5432")}},
+		},
+		{
+			name: "branch replaced by scalar",
+			a:    Branch{{Key: "db", Value: Branch{{Key: "user", Value: "bob"}}}},
+			b:    Branch{{Key: "db", Value: "bob"}},
+			want: []Change{{Kind: Changed, Path: Path{"db"}, Old: Branch{{Key: "user", Value: "bob"}}, New: "bob"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Diff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Diff() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffPathsDoNotShareStorage(t *testing.T) {
+	a := Branch{{Key: "a", Value: Branch{{Key: "b", Value: "1"}, {Key: "c", Value: "1"}, {Key: "d", Value: "1"}}}}
+	b := Branch{{Key: "a", Value: Branch{{Key: "b", Value: "2"}, {Key: "c", Value: "2"}, {Key: "d", Value: "2"}}}}
+	var got []string
+	for _, c := range Diff(a, b) {
+		got = append(got, c.Path.String())
+	}
+	if want := []string{"a.b", "a.c", "a.d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() paths = %v, want %v", got, want)
+	}
+}
+
+func TestIsLeaf(t *testing.T) {
+	tests := []struct {
+		value any
+		want  bool
+	}{
+		{value: "s", want: true},
+		{value: nil, want: true},
+		{value: 1, want: true},
+		{value: Branch{}, want: false},
+		{value: []any{}, want: false},
+	}
+	for _, tt := range tests {
+		if got := IsLeaf(tt.value); got != tt.want {
+			t.Errorf("IsLeaf(%#v) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package sopsfile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Marshal writes a plaintext tree in the given format, keeping key order.
+func Marshal(tree Branch, format Format) ([]byte, error) {
+	switch format {
+	case FormatYAML:
+		node, err := yamlNode(tree)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(4)
+		if err := enc.Encode(node); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), enc.Close()
+	case FormatJSON:
+		var buf bytes.Buffer
+		if err := writeJSON(&buf, tree, ""); err != nil {
+			return nil, err
+		}
+		buf.WriteByte('\n')
+		return buf.Bytes(), nil
+	case FormatDotenv:
+		var buf bytes.Buffer
+		for _, item := range tree {
+			if !IsLeaf(item.Value) {
+				return nil, fmt.Errorf("dotenv does not support nested key %q", item.Key)
+			}
+			value := strings.ReplaceAll(ScalarString(item.Value), "\n", `\n`)
+			fmt.Fprintf(&buf, "%s=%s\n", item.Key, value)
+		}
+		return buf.Bytes(), nil
+	case FormatINI:
+		return marshalINI(tree)
+	case FormatBinary:
+		data, _ := tree.Get("data")
+		return []byte(ScalarString(data)), nil
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+func yamlNode(v any) (*yaml.Node, error) {
+	switch t := v.(type) {
+	case Branch:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, item := range t {
+			value, err := yamlNode(item.Value)
+			if err != nil {
+				return nil, err
+			}
+			key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item.Key}
+			node.Content = append(node.Content, key, value)
+		}
+		return node, nil
+	case []any:
+		node := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, item := range t {
+			value, err := yamlNode(item)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		return node, nil
+	case json.Number:
+		// Untagged plain scalar, resolved as int or float
+		return &yaml.Node{Kind: yaml.ScalarNode, Value: t.String()}, nil
+	}
+
+	node := &yaml.Node{}
+	if err := node.Encode(v); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func writeJSON(buf *bytes.Buffer, v any, indent string) error {
+	inner := indent + "    "
+	switch t := v.(type) {
+	case Branch:
+		if len(t) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteString("{\n")
+		for i, item := range t {
+			key, _ := json.Marshal(item.Key)
+			buf.WriteString(inner)
+			buf.Write(key)
+			buf.WriteString(": ")
+			if err := writeJSON(buf, item.Value, inner); err != nil {
+				return err
+			}
+			if i < len(t)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "}")
+	case []any:
+		if len(t) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteString("[\n")
+		for i, item := range t {
+			buf.WriteString(inner)
+			if err := writeJSON(buf, item, inner); err != nil {
+				return err
+			}
+			if i < len(t)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(indent + "]")
+	default:
+		out, err := json.Marshal(t)
+		if err != nil {
+			return err
+		}
+		buf.Write(out)
+	}
+	return nil
+}
+
+func marshalINI(tree Branch) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, item := range tree {
+		if IsLeaf(item.Value) {
+			fmt.Fprintf(&buf, "%s = %s\n", item.Key, ScalarString(item.Value))
+		}
+	}
+	for _, item := range tree {
+		section, ok := item.Value.(Branch)
+		if !ok {
+			if !IsLeaf(item.Value) {
+				return nil, fmt.Errorf("ini does not support lists (key %q)", item.Key)
+			}
+			continue
+		}
+		fmt.Fprintf(&buf, "[%s]\n", item.Key)
+		for _, kv := range section {
+			if !IsLeaf(kv.Value) {
+				return nil, fmt.Errorf("ini does not support nested key %q", kv.Key)
+			}
+			fmt.Fprintf(&buf, "%s = %s\n", kv.Key, ScalarString(kv.Value))
+		}
+	}
+	return buf.Bytes(), nil
+}
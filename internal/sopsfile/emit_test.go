@@ -0,0 +1,123 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMarshal(t *testing.T) {
+	tree := Branch{
+		{Key: "name", Value: "app"},
+		{Key: "port", Value: json.Number("8080")},
+		{Key: "db", Value: Branch{{Key: "user", Value: "bob"}, {Key: "password", Value: "p"}}},
+	}
+
+	tests := []struct {
+		name    string
+		tree    Branch
+		format  Format
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "yaml",
+			tree:   tree,
+			format: FormatYAML,
+			want:   "name: app\nport: 8080\ndb:\n    user: bob\n    password: p\n",
+		},
+		{
+			name:   "yaml list",
+			tree:   Branch{{Key: "l", Value: []any{"a", 1, true}}},
+			format: FormatYAML,
+			want:   "l:\n    - a\n    - 1\n    - true\n",
+		},
+		{
+			name:   "json",
+			tree:   tree,
+			format: FormatJSON,
+			want:   "{\n    \"name\": \"app\",\n    \"port\": 8080,\n    \"db\": {\n        \"user\": \"bob\",\n        \"password\": \"p\"\n    }\n}\n",
+		},
+		{
+			name:   "json empty values",
+			tree:   Branch{{Key: "m", Value: Branch{}}, {Key: "l", Value: []any{}}, {Key: "n", Value: nil}},
+			format: FormatJSON,
+			want:   "{\n    \"m\": {},\n    \"l\": [],\n    \"n\": null\n}\n",
+		},
+		{
+			name:   "dotenv",
+			tree:   Branch{{Key: "A", Value: "1"}, {Key: "B", Value: "line1\nline2"}},
+			format: FormatDotenv,
+			want:   "A=1\nB=line1\\nline2\n",
+		},
+		{
+			name:    "dotenv nested",
+			tree:    tree,
+			format:  FormatDotenv,
+			wantErr: true,
+		},
+		{
+			name:   "ini",
+			tree:   tree,
+			format: FormatINI,
+			want:   "name = app\nport = 8080\n[db]\nuser = bob\npassword = p\n",
+		},
+		{
+			name:    "ini list",
+			tree:    Branch{{Key: "l", Value: []any{"a"}}},
+			format:  FormatINI,
+			wantErr: true,
+		},
+		{
+			name:    "ini nested section",
+			tree:    Branch{{Key: "s", Value: Branch{{Key: "n", Value: Branch{}}}}},
+			format:  FormatINI,
+			wantErr: true,
+		},
+		{
+			name:   "binary",
+			tree:   Branch{{Key: "data", Value: "raw bytes\n"}},
+			format: FormatBinary,
+			want:   "raw bytes\n",
+		},
+		{
+			name:    "unsupported",
+			tree:    tree,
+			format:  Format("toml"),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Marshal(tt.tree, tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Marshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("Marshal() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	tree := Branch{
+		{Key: "name", Value: "app"},
+		{Key: "db", Value: Branch{{Key: "user", Value: "bob"}, {Key: "password", Value: "p"}}},
+	}
+	for _, format := range []Format{FormatYAML, FormatJSON, FormatINI} {
+		t.Run(string(format), func(t *testing.T) {
+			data, err := Marshal(tree, format)
+			if err != nil {
+				t.Fatalf("Marshal() error = %v", err)
+			}
+			got, err := ParseTree(data, format)
+			if err != nil {
+				t.Fatalf("ParseTree() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tree) {
+				t.Errorf("round trip = %#v, want %#v", got, tree)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Flatten returns the leaves of tree in document order, with nested keys and list
+// indexes joined by separator. Values are rendered with ScalarString.
+func Flatten(tree Branch, separator string) []Item {
+	var items []Item
+	flatten(tree, "", separator, &items)
+	return items
+}
+
+func flatten(v any, prefix, separator string, items *[]Item) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + separator + key
+	}
+
+	switch t := v.(type) {
+	case Branch:
+		for _, item := range t {
+			flatten(item.Value, join(item.Key), separator, items)
+		}
+	case []any:
+		for i, item := range t {
+			flatten(item, join(strconv.Itoa(i)), separator, items)
+		}
+	default:
+		*items = append(*items, Item{Key: prefix, Value: ScalarString(v)})
+	}
+}
+
+// Walk calls fn for every leaf of tree in document order.
+func Walk(tree Branch, fn func(path Path, value any)) {
+	walk(nil, tree, fn)
+}
+
+func walk(path Path, v any, fn func(Path, any)) {
+	switch t := v.(type) {
+	case Branch:
+		for _, item := range t {
+			walk(appendPath(path, item.Key), item.Value, fn)
+		}
+	case []any:
+		for i, item := range t {
+			walk(appendPath(path, i), item, fn)
+		}
+	default:
+		fn(path, v)
+	}
+}
+
+// ScalarString renders a scalar tree value as plain text. Nil becomes an empty string.
+func ScalarString(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case time.Time:
+		return t.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(t)
+	}
+}
@@ -0,0 +1,96 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFlatten(t *testing.T) {
+	tree := Branch{
+		{Key: "db", Value: Branch{
+			{Key: "user", Value: "bob"},
+			{Key: "port", Value: 5432},
+		}},
+		{Key: "tokens", Value: []any{"a", Branch{{Key: "id", Value: json.Number("7")}}}},
+		{Key: "enabled", Value: true},
+		{Key: "empty", Value: nil},
+	}
+
+	tests := []struct {
+		separator string
+		want      []Item
+	}{
+		{
+			separator: ".",
+			want: []Item{
+				{Key: "db.user", Value: "bob"},
+				{Key: "db.port", Value: "5432"},
+				{Key: "tokens.0", Value: "a"},
+				{Key: "tokens.1.id", Value: "7"},
+				{Key: "enabled", Value: "true"},
+				{Key: "empty", Value: ""},
+			},
+		},
+		{
+			separator: "_",
+			want: []Item{
+				{Key: "db_user", Value: "bob"},
+				{Key: "db_port", Value: "5432"},
+				{Key: "tokens_0", Value: "a"},
+				{Key: "tokens_1_id", Value: "7"},
+				{Key: "enabled", Value: "true"},
+				{Key: "empty", Value: ""},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.separator, func(t *testing.T) {
+			if got := Flatten(tree, tt.separator); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Flatten() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScalarString(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{value: nil, want: ""},
+		{value: "text", want: "text"},
+		{value: 42, want: "42"},
+		{value: 1.5, want: "1.5"},
+		{value: false, want: "false"},
+		{value: json.Number("1e3"), want: "1e3"},
+		{value: time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC), want: "2024-03-01T10:20:30Z"},
+	}
+	for _, tt := range tests {
+		if got := ScalarString(tt.value); got != tt.want {
+			t.Errorf("ScalarString(%#v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	tree := Branch{
+		{Key: "a", Value: Branch{{Key: "b", Value: 1}}},
+		{Key: "c", Value: []any{"x", []any{"y"}}},
+	}
+	var paths []Path
+	var values []any
+	Walk(tree, func(path Path, value any) {
+		paths = append(paths, path)
+		values = append(values, value)
+	})
+
+	wantPaths := []Path{{"a", "b"}, {"c", 0}, {"c", 1, 0}}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("Walk() paths = %v, want %v", paths, wantPaths)
+	}
+	if want := []any{1, "x", "y"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("Walk() values = %v, want %v", values, want)
+	}
+}
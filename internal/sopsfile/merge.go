@@ -0,0 +1,73 @@
+package sopsfile
+
+import "reflect"
+
+// Conflict is a value that was changed differently on both sides of a merge.
+type Conflict struct {
+	Path Path
+
+	Ours     any
+	Theirs   any
+	InOurs   bool
+	InTheirs bool
+}
+
+// Merge3 performs a three-way merge of two trees that share base. Keys changed on one side
+// only are taken from that side; keys changed on both sides in different ways are
+// reported as conflicts and keep our value. Lists are merged as whole values.
+func Merge3(base, ours, theirs Branch) (Branch, []Conflict) {
+	var conflicts []Conflict
+	merged, _ := merge3(nil, base, ours, theirs, true, true, true, &conflicts)
+	tree, _ := merged.(Branch)
+	return tree, conflicts
+}
+
+func merge3(path Path, b, o, t any, bOk, oOk, tOk bool, conflicts *[]Conflict) (any, bool) {
+	switch {
+	case sameValue(o, oOk, t, tOk):
+		return o, oOk
+	case sameValue(b, bOk, o, oOk):
+		return t, tOk
+	case sameValue(b, bOk, t, tOk):
+		return o, oOk
+	}
+
+	ob, oIsBranch := o.(Branch)
+	tb, tIsBranch := t.(Branch)
+	bb, bIsBranch := b.(Branch)
+	if oIsBranch && tIsBranch && (bIsBranch || !bOk) {
+		return mergeBranches(path, bb, ob, tb, conflicts), true
+	}
+
+	*conflicts = append(*conflicts, Conflict{Path: path, Ours: o, Theirs: t, InOurs: oOk, InTheirs: tOk})
+	return o, oOk
+}
+
+func mergeBranches(path Path, base, ours, theirs Branch, conflicts *[]Conflict) Branch {
+	merged := Branch{}
+	add := func(key string) {
+		b, bOk := base.Get(key)
+		o, oOk := ours.Get(key)
+		t, tOk := theirs.Get(key)
+		if v, ok := merge3(appendPath(path, key), b, o, t, bOk, oOk, tOk, conflicts); ok {
+			merged = append(merged, Item{Key: key, Value: v})
+		}
+	}
+
+	for _, item := range ours {
+		add(item.Key)
+	}
+	for _, item := range theirs {
+		if _, ok := ours.Get(item.Key); !ok {
+			add(item.Key)
+		}
+	}
+	return merged
+}
+
+func sameValue(a any, aOk bool, b any, bOk bool) bool {
+	if aOk != bOk {
+		return false
+	}
+	return !aOk || reflect.DeepEqual(a, b)
+}
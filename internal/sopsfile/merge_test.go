@@ -0,0 +1,105 @@
+package sopsfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	base := Branch{
+		{Key: "a", Value: "1"},
+		{Key: "b", Value: "1"},
+		{Key: "db", Value: Branch{{Key: "user", Value: "bob"}, {Key: "password", Value: "p1"}}},
+	}
+
+	tests := []struct {
+		name          string
+		ours, theirs  Branch
+		want          Branch
+		wantConflicts []Conflict
+	}{
+		{
+			name:   "unchanged",
+			ours:   base,
+			theirs: base,
+			want:   base,
+		},
+		{
+			name:   "changed on one side each",
+			ours:   Branch{{Key: "a", Value: "2"}, {Key: "b", Value: "1"}, base[2]},
+			theirs: Branch{{Key: "a", Value: "1"}, {Key: "b", Value: "3"}, base[2]},
+			want:   Branch{{Key: "a", Value: "2"}, {Key: "b", Value: "3"}, base[2]},
+		},
+		{
+			name:   "same change on both sides",
+			ours:   Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+			theirs: Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+			want:   Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+		},
+		{
+			name:   "added on both sides",
+			ours:   append(append(Branch{}, base...), Item{Key: "ours", Value: "o"}),
+			theirs: append(append(Branch{}, base...), Item{Key: "theirs", Value: "t"}),
+			want:   append(append(Branch{}, base...), Item{Key: "ours", Value: "o"}, Item{Key: "theirs", Value: "t"}),
+		},
+		{
+			name:   "removed on one side",
+			ours:   Branch{base[1], base[2]},
+			theirs: base,
+			want:   Branch{base[1], base[2]},
+		},
+		{
+			name:   "nested branches merge key by key",
+			ours:   Branch{base[0], base[1], {Key: "db", Value: Branch{{Key: "user", Value: "alice"}, {Key: "password", Value: "p1"}}}},
+			theirs: Branch{base[0], base[1], {Key: "db", Value: Branch{{Key: "user", Value: "bob"}, {Key: "password", Value: "p2"}}}},
+			want:   Branch{base[0], base[1], {Key: "db", Value: Branch{{Key: "user", Value: "alice"}, {Key: "password", Value: "p2"}}}},
+		},
+		{
+			name:   "conflict keeps ours",
+			ours:   Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+			theirs: Branch{{Key: "a", Value: "3"}, base[1], base[2]},
+			want:   Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+			wantConflicts: []Conflict{
+				{Path: Path{"a"}, Ours: "2", Theirs: "3", InOurs: true, InTheirs: true},
+			},
+		},
+		{
+			name:   "changed on one side, removed on the other",
+			ours:   Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+			theirs: Branch{base[1], base[2]},
+			want:   Branch{{Key: "a", Value: "2"}, base[1], base[2]},
+			wantConflicts: []Conflict{
+				{Path: Path{"a"}, Ours: "2", InOurs: true},
+			},
+		},
+		{
+			name:   "removed on our side, changed on theirs",
+			ours:   Branch{base[1], base[2]},
+			theirs: Branch{{Key: "a", Value: "3"}, base[1], base[2]},
+			want:   Branch{base[1], base[2]},
+			wantConflicts: []Conflict{
+				{Path: Path{"a"}, Theirs: "3", InTheirs: true},
+			},
+		},
+		{
+			name:   "lists merge as whole values",
+			ours:   append(append(Branch{}, base...), Item{Key: "l", Value: []any{"x", "o"}}),
+			theirs: append(append(Branch{}, base...), Item{Key: "l", Value: []any{"x", "t"}}),
+			want:   append(append(Branch{}, base...), Item{Key: "l", Value: []any{"x", "o"}}),
+			wantConflicts: []Conflict{
+				{Path: Path{"l"}, Ours: []any{"x", "o"}, Theirs: []any{"x", "t"}, InOurs: true, InTheirs: true},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := Merge3(base, tt.ours, tt.theirs)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Merge3() tree = %#v, want %#v", got, tt.want)
+			}
+			if !reflect.DeepEqual(conflicts, tt.wantConflicts) {
+				t.Errorf("Merge3() conflicts = %#v, want %#v", conflicts, tt.wantConflicts)
+			}
+		})
+	}
+}
@@ -0,0 +1,295 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backend names as they appear in the sops metadata.
+const (
+	BackendAge     = "age"
+	BackendPGP     = "pgp"
+	BackendKMS     = "kms"
+	BackendGCPKMS  = "gcp_kms"
+	BackendAzureKV = "azure_kv"
+	BackendHCVault = "hc_vault"
+)
+
+// Metadata is the sops metadata block of an encrypted file.
+type Metadata struct {
+	// KeyGroup holds the master keys listed directly under "sops".
+	KeyGroup
+	// KeyGroups holds the master keys of files encrypted with key_groups.
+	KeyGroups       []KeyGroup
+	ShamirThreshold int
+
+	LastModified time.Time
+	MAC          string
+	Version      string
+
+	EncryptedRegex          string
+	UnencryptedRegex        string
+	EncryptedSuffix         string
+	UnencryptedSuffix       string
+	EncryptedCommentRegex   string
+	UnencryptedCommentRegex string
+	MACOnlyEncrypted        bool
+}
+
+// KeyGroup is a set of master keys, one per recipient.
+type KeyGroup struct {
+	Age     []AgeKey
+	PGP     []PGPKey
+	KMS     []KMSKey
+	GCPKMS  []GCPKMSKey
+	AzureKV []AzureKVKey
+	HCVault []HCVaultKey
+}
+
+// AgeKey is an age recipient entry in the metadata.
+type AgeKey struct {
+	Recipient string
+}
+
+// PGPKey is a PGP recipient entry in the metadata.
+type PGPKey struct {
+	Fingerprint string
+}
+
+// KMSKey is an AWS KMS entry in the metadata.
+type KMSKey struct {
+	ARN        string
+	Role       string
+	Context    map[string]string
+	AWSProfile string
+}
+
+// GCPKMSKey is a GCP KMS entry in the metadata.
+type GCPKMSKey struct {
+	ResourceID string
+}
+
+// AzureKVKey is an Azure Key Vault entry in the metadata.
+type AzureKVKey struct {
+	VaultURL string
+	Name     string
+	Version  string
+}
+
+// HCVaultKey is a HashiCorp Vault transit entry in the metadata.
+type HCVaultKey struct {
+	VaultAddress string
+	EnginePath   string
+	KeyName      string
+}
+
+// HasMAC returns true if the metadata contains a message authentication code.
+func (m *Metadata) HasMAC() bool {
+	return m.MAC != ""
+}
+
+// Validate checks that the metadata is complete enough for sops to decrypt the file.
+func (m *Metadata) Validate() error {
+	switch {
+	case m.Version == "":
+		return fmt.Errorf("sops metadata has no version")
+	case !m.HasMAC():
+		return fmt.Errorf("sops metadata has no mac")
+	case len(m.Recipients()) == 0:
+		return fmt.Errorf("sops metadata has no keys")
+	}
+	return nil
+}
+
+// Groups returns the key groups of the file. Files without key_groups have a single
+// group made of the top-level keys.
+func (m *Metadata) Groups() []KeyGroup {
+	if len(m.KeyGroups) > 0 {
+		return m.KeyGroups
+	}
+	return []KeyGroup{m.KeyGroup}
+}
+
+// AgeRecipients returns the age public keys the file is encrypted for, across all key groups.
+func (m *Metadata) AgeRecipients() []string {
+	return m.Recipients()[BackendAge]
+}
+
+// Recipients returns the recipients of every key group, per backend.
+func (m *Metadata) Recipients() map[string][]string {
+	all := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, g := range m.Groups() {
+		for backend, ids := range g.Recipients() {
+			for _, id := range ids {
+				if seen[backend+"\x00"+id] {
+					continue
+				}
+				seen[backend+"\x00"+id] = true
+				all[backend] = append(all[backend], id)
+			}
+		}
+	}
+	return all
+}
+
+// Recipients returns a stable identifier for each key in the group, per backend.
+func (g KeyGroup) Recipients() map[string][]string {
+	r := make(map[string][]string)
+	for _, k := range g.Age {
+		r[BackendAge] = append(r[BackendAge], k.Recipient)
+	}
+	for _, k := range g.PGP {
+		r[BackendPGP] = append(r[BackendPGP], k.Fingerprint)
+	}
+	for _, k := range g.KMS {
+		r[BackendKMS] = append(r[BackendKMS], k.ARN)
+	}
+	for _, k := range g.GCPKMS {
+		r[BackendGCPKMS] = append(r[BackendGCPKMS], k.ResourceID)
+	}
+	for _, k := range g.AzureKV {
+		r[BackendAzureKV] = append(r[BackendAzureKV], strings.TrimSuffix(k.VaultURL, "/")+"/keys/"+k.Name+"/"+k.Version)
+	}
+	for _, k := range g.HCVault {
+		r[BackendHCVault] = append(r[BackendHCVault], strings.TrimSuffix(k.VaultAddress, "/")+"/v1/"+k.EnginePath+"/keys/"+k.KeyName)
+	}
+	return r
+}
+
+// newMetadata converts the untyped "sops" section into Metadata.
+func newMetadata(raw map[string]any) *Metadata {
+	meta := &Metadata{
+		KeyGroup:                newKeyGroup(raw),
+		ShamirThreshold:         asInt(raw["shamir_threshold"]),
+		LastModified:            asTime(raw["lastmodified"]),
+		MAC:                     asString(raw["mac"]),
+		Version:                 asString(raw["version"]),
+		EncryptedRegex:          asString(raw["encrypted_regex"]),
+		UnencryptedRegex:        asString(raw["unencrypted_regex"]),
+		EncryptedSuffix:         asString(raw["encrypted_suffix"]),
+		UnencryptedSuffix:       asString(raw["unencrypted_suffix"]),
+		EncryptedCommentRegex:   asString(raw["encrypted_comment_regex"]),
+		UnencryptedCommentRegex: asString(raw["unencrypted_comment_regex"]),
+		MACOnlyEncrypted:        asBool(raw["mac_only_encrypted"]),
+	}
+	for _, g := range asList(raw["key_groups"]) {
+		if m, ok := g.(map[string]any); ok {
+			meta.KeyGroups = append(meta.KeyGroups, newKeyGroup(m))
+		}
+	}
+	return meta
+}
+
+func newKeyGroup(raw map[string]any) KeyGroup {
+	var g KeyGroup
+	for _, m := range asMaps(raw[BackendAge]) {
+		g.Age = append(g.Age, AgeKey{Recipient: asString(m["recipient"])})
+	}
+	for _, m := range asMaps(raw[BackendPGP]) {
+		g.PGP = append(g.PGP, PGPKey{Fingerprint: asString(m["fp"])})
+	}
+	for _, m := range asMaps(raw[BackendKMS]) {
+		k := KMSKey{
+			ARN:        asString(m["arn"]),
+			Role:       asString(m["role"]),
+			AWSProfile: asString(m["aws_profile"]),
+		}
+		if ctx, ok := m["context"].(map[string]any); ok {
+			k.Context = make(map[string]string, len(ctx))
+			for key, v := range ctx {
+				k.Context[key] = asString(v)
+			}
+		}
+		g.KMS = append(g.KMS, k)
+	}
+	for _, m := range asMaps(raw[BackendGCPKMS]) {
+		g.GCPKMS = append(g.GCPKMS, GCPKMSKey{ResourceID: asString(m["resource_id"])})
+	}
+	for _, m := range asMaps(raw[BackendAzureKV]) {
+		g.AzureKV = append(g.AzureKV, AzureKVKey{
+			VaultURL: asString(m["vault_url"]),
+			Name:     asString(m["name"]),
+			Version:  asString(m["version"]),
+		})
+	}
+	for _, m := range asMaps(raw[BackendHCVault]) {
+		g.HCVault = append(g.HCVault, HCVaultKey{
+			VaultAddress: asString(m["vault_address"]),
+			EnginePath:   asString(m["engine_path"]),
+			KeyName:      asString(m["key_name"]),
+		})
+	}
+	return g
+}
+
+func asList(v any) []any {
+	l, _ := v.([]any)
+	return l
+}
+
+func asMaps(v any) []map[string]any {
+	var maps []map[string]any
+	for _, item := range asList(v) {
+		if m, ok := item.(map[string]any); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
+
+func asString(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return s
+	case time.Time:
+		return s.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+func asInt(v any) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+}
+
+func asBool(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		parsed, _ := strconv.ParseBool(b)
+		return parsed
+	}
+	return false
+}
+
+func asTime(v any) time.Time {
+	switch t := v.(type) {
+	case time.Time:
+		return t
+	case string:
+		parsed, err := time.Parse(time.RFC3339, t)
+		if err == nil {
+			return parsed
+		}
+	}
+	return time.Time{}
+}
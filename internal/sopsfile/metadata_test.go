@@ -0,0 +1,118 @@
+package sopsfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadMetadata(t *testing.T) {
+	tests := []struct {
+		file string
+		want func(t *testing.T, m *Metadata)
+	}{
+		{
+			file: "secrets.yaml",
+			want: func(t *testing.T, m *Metadata) {
+				if len(m.KeyGroups) != 0 || len(m.Groups()) != 1 {
+					t.Errorf("Groups() = %d groups, want the top-level group only", len(m.Groups()))
+				}
+				if m.UnencryptedSuffix != "_unencrypted" || m.MACOnlyEncrypted {
+					t.Errorf("UnencryptedSuffix = %q, MACOnlyEncrypted = %v, want _unencrypted and false", m.UnencryptedSuffix, m.MACOnlyEncrypted)
+				}
+				if !m.HasMAC() {
+					t.Error("HasMAC() = false")
+				}
+			},
+		},
+		{
+			file: "secrets.json",
+			want: func(t *testing.T, m *Metadata) {
+				if len(m.Groups()) != 2 || m.ShamirThreshold != 2 {
+					t.Errorf("got %d groups with threshold %d, want 2 and 2", len(m.Groups()), m.ShamirThreshold)
+				}
+				want := KMSKey{
+					ARN:        "arn:aws:kms:eu-west-1:111122223333:key/abcd",
+					Role:       "arn:aws:iam::111122223333:role/sops",
+					Context:    map[string]string{"env": "prod"},
+					AWSProfile: "prod",
+				}
+				if kms := m.Groups()[0].KMS; len(kms) != 1 || !reflect.DeepEqual(kms[0], want) {
+					t.Errorf("KMS = %+v, want %+v", kms, want)
+				}
+				if m.EncryptedRegex != "^(api_key|replicas)$" || !m.MACOnlyEncrypted {
+					t.Errorf("EncryptedRegex = %q, MACOnlyEncrypted = %v", m.EncryptedRegex, m.MACOnlyEncrypted)
+				}
+			},
+		},
+		{
+			file: "secrets.env",
+			want: func(t *testing.T, m *Metadata) {
+				want := []AgeKey{{Recipient: ageAlice}, {Recipient: ageBob}}
+				if !reflect.DeepEqual(m.Age, want) {
+					t.Errorf("Age = %+v, want %+v", m.Age, want)
+				}
+				if !m.MACOnlyEncrypted || m.ShamirThreshold != 1 {
+					t.Errorf("MACOnlyEncrypted = %v, ShamirThreshold = %d, want true and 1", m.MACOnlyEncrypted, m.ShamirThreshold)
+				}
+			},
+		},
+		{
+			file: "secrets.ini",
+			want: func(t *testing.T, m *Metadata) {
+				want := []AzureKVKey{{VaultURL: "https://vault.vault.azure.net/", Name: "sops-key", Version: "0123"}}
+				if !reflect.DeepEqual(m.AzureKV, want) {
+					t.Errorf("AzureKV = %+v, want %+v", m.AzureKV, want)
+				}
+				if m.AgeRecipients() != nil {
+					t.Errorf("AgeRecipients() = %v, want none", m.AgeRecipients())
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			m, err := ReadMetadata(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("ReadMetadata() error = %v", err)
+			}
+			tt.want(t, m)
+		})
+	}
+}
+
+func TestMetadataValidate(t *testing.T) {
+	complete := KeyGroup{Age: []AgeKey{{Recipient: ageAlice}}}
+
+	tests := []struct {
+		name    string
+		meta    Metadata
+		wantErr bool
+	}{
+		{name: "complete", meta: Metadata{KeyGroup: complete, MAC: "ENC[...]", Version: "3.8.1"}},
+		{name: "key groups", meta: Metadata{KeyGroups: []KeyGroup{complete}, MAC: "ENC[...]", Version: "3.8.1"}},
+		{name: "no version", meta: Metadata{KeyGroup: complete, MAC: "ENC[...]"}, wantErr: true},
+		{name: "no mac", meta: Metadata{KeyGroup: complete, Version: "3.8.1"}, wantErr: true},
+		{name: "no keys", meta: Metadata{MAC: "ENC[...]", Version: "3.8.1"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.meta.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMetadataValidateFixtures(t *testing.T) {
+	for _, file := range []string{"secrets.yaml", "secrets.json", "secrets.env", "secrets.ini", "secrets.bin"} {
+		m, err := ReadMetadata(filepath.Join("testdata", file))
+		if err != nil {
+			t.Fatalf("ReadMetadata(%s) error = %v", file, err)
+		}
+		if err := m.Validate(); err != nil {
+			t.Errorf("Validate(%s) error = %v", file, err)
+		}
+	}
+}
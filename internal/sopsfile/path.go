@@ -0,0 +1,137 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path addresses a value in a tree. Elements are map keys (string) or list indexes (int).
+type Path []any
+
+// ParsePath parses a dotted or JSONPath-like expression such as "db.password",
+// "users[0].token" or `$["a.b"].c`.
+func ParsePath(expr string) (Path, error) {
+	s := strings.TrimPrefix(strings.TrimSpace(expr), "$")
+	var path Path
+
+	for len(s) > 0 {
+		switch s[0] {
+		case '.':
+			s = s[1:]
+		case '[':
+			inner := strings.TrimLeft(s[1:], " ")
+			if inner != "" && (inner[0] == '"' || inner[0] == '\'') {
+				// Quoted keys may contain dots and brackets
+				quote := inner[0]
+				closing := strings.IndexByte(inner[1:], quote)
+				if closing < 0 {
+					return nil, fmt.Errorf("invalid path %q: unterminated quote", expr)
+				}
+				rest := strings.TrimLeft(inner[closing+2:], " ")
+				if !strings.HasPrefix(rest, "]") {
+					return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+				}
+				path = append(path, inner[1:closing+1])
+				s = rest[1:]
+				continue
+			}
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", expr)
+			}
+			idx, err := strconv.Atoi(strings.TrimSpace(s[1:end]))
+			if err != nil || idx < 0 {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", expr, s[1:end])
+			}
+			path = append(path, idx)
+			s = s[end+1:]
+		default:
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			path = append(path, s[:end])
+			s = s[end:]
+		}
+	}
+
+	if len(path) == 0 {
+		return nil, fmt.Errorf("invalid path %q: empty", expr)
+	}
+	return path, nil
+}
+
+// String returns the path in dotted notation.
+func (p Path) String() string {
+	var b strings.Builder
+	for _, elem := range p {
+		switch e := elem.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", e)
+		case string:
+			if strings.ContainsAny(e, ".[]") {
+				fmt.Fprintf(&b, "[%q]", e)
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(e)
+		}
+	}
+	return b.String()
+}
+
+// SOPSIndex returns the path in the index syntax used by sops --extract and sops set,
+// e.g. ["users"][0]["token"].
+func (p Path) SOPSIndex() string {
+	var b strings.Builder
+	for _, elem := range p {
+		switch e := elem.(type) {
+		case int:
+			fmt.Fprintf(&b, "[%d]", e)
+		case string:
+			key, _ := json.Marshal(e)
+			fmt.Fprintf(&b, "[%s]", key)
+		}
+	}
+	return b.String()
+}
+
+// Keys returns the map keys along the path, which is what sops matches its
+// encryption rules against.
+func (p Path) Keys() []string {
+	var keys []string
+	for _, elem := range p {
+		if k, ok := elem.(string); ok {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// Lookup returns the value at path in tree.
+func Lookup(tree Branch, path Path) (any, bool) {
+	var node any = tree
+	for _, elem := range path {
+		switch e := elem.(type) {
+		case string:
+			branch, ok := node.(Branch)
+			if !ok {
+				return nil, false
+			}
+			if node, ok = branch.Get(e); !ok {
+				return nil, false
+			}
+		case int:
+			list, ok := node.([]any)
+			if !ok || e >= len(list) {
+				return nil, false
+			}
+			node = list[e]
+		}
+	}
+	return node, true
+}
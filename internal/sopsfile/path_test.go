@@ -0,0 +1,111 @@
+package sopsfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    Path
+		wantErr bool
+	}{
+		{expr: "db", want: Path{"db"}},
+		{expr: "db.password", want: Path{"db", "password"}},
+		{expr: "users[0].token", want: Path{"users", 0, "token"}},
+		{expr: "matrix[1][2]", want: Path{"matrix", 1, 2}},
+		{expr: "$.db.password", want: Path{"db", "password"}},
+		{expr: `$["a.b"].c`, want: Path{"a.b", "c"}},
+		{expr: `['x[0]']`, want: Path{"x[0]"}},
+		{expr: `[ "spaced" ]`, want: Path{"spaced"}},
+		{expr: "  db.user  ", want: Path{"db", "user"}},
+		{expr: "", wantErr: true},
+		{expr: "$", wantErr: true},
+		{expr: "users[0", wantErr: true},
+		{expr: "users[-1]", wantErr: true},
+		{expr: "users[x]", wantErr: true},
+		{expr: `["open`, wantErr: true},
+		{expr: `["key"`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := ParsePath(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParsePath(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePath(%q) = %#v, want %#v", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPathFormats(t *testing.T) {
+	tests := []struct {
+		path      Path
+		str       string
+		sopsIndex string
+		keys      []string
+	}{
+		{path: Path{"db", "password"}, str: "db.password", sopsIndex: `["db"]["password"]`, keys: []string{"db", "password"}},
+		{path: Path{"users", 0, "token"}, str: "users[0].token", sopsIndex: `["users"][0]["token"]`, keys: []string{"users", "token"}},
+		{path: Path{"a.b", "c"}, str: `["a.b"].c`, sopsIndex: `["a.b"]["c"]`, keys: []string{"a.b", "c"}},
+		{path: Path{`say "hi"`}, str: `say "hi"`, sopsIndex: `["say \"hi\""]`, keys: []string{`say "hi"`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.str, func(t *testing.T) {
+			if got := tt.path.String(); got != tt.str {
+				t.Errorf("String() = %q, want %q", got, tt.str)
+			}
+			if got := tt.path.SOPSIndex(); got != tt.sopsIndex {
+				t.Errorf("SOPSIndex() = %q, want %q", got, tt.sopsIndex)
+			}
+			if got := tt.path.Keys(); !reflect.DeepEqual(got, tt.keys) {
+				t.Errorf("Keys() = %v, want %v", got, tt.keys)
+			}
+		})
+	}
+}
+
+func TestPathStringRoundTrip(t *testing.T) {
+	for _, path := range []Path{{"db", "password"}, {"users", 0, "token"}, {"a.b", "c"}, {"list", 1, 2}} {
+		parsed, err := ParsePath(path.String())
+		if err != nil {
+			t.Fatalf("ParsePath(%q) error = %v", path.String(), err)
+		}
+		if !reflect.DeepEqual(parsed, path) {
+			t.Errorf("ParsePath(%q) = %#v, want %#v", path.String(), parsed, path)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	tree := Branch{
+		{Key: "db", Value: Branch{{Key: "password", Value: "secret"}}},
+		{Key: "users", Value: []any{Branch{{Key: "token", Value: "t0"}}}},
+		{Key: "empty", Value: nil},
+	}
+	tests := []struct {
+		path   Path
+		want   any
+		wantOk bool
+	}{
+		{path: Path{"db", "password"}, want: "secret", wantOk: true},
+		{path: Path{"users", 0, "token"}, want: "t0", wantOk: true},
+		{path: Path{"db"}, want: Branch{{Key: "password", Value: "secret"}}, wantOk: true},
+		{path: Path{"empty"}, want: nil, wantOk: true},
+		{path: Path{"db", "user"}},
+		{path: Path{"users", 1}},
+		{path: Path{"users", "token"}},
+		{path: Path{"db", 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path.String(), func(t *testing.T) {
+			got, ok := Lookup(tree, tt.path)
+			if ok != tt.wantOk || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
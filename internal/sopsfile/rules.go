@@ -0,0 +1,89 @@
+package sopsfile
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultUnencryptedSuffix is the suffix sops leaves unencrypted when no other rule is set.
+const DefaultUnencryptedSuffix = "_unencrypted"
+
+// Rules are the sops options that decide which values get encrypted.
+type Rules struct {
+	EncryptedRegex    string
+	UnencryptedRegex  string
+	EncryptedSuffix   string
+	UnencryptedSuffix string
+}
+
+// Rules returns the encryption rules recorded in the metadata.
+func (m *Metadata) Rules() Rules {
+	return Rules{
+		EncryptedRegex:    m.EncryptedRegex,
+		UnencryptedRegex:  m.UnencryptedRegex,
+		EncryptedSuffix:   m.EncryptedSuffix,
+		UnencryptedSuffix: m.UnencryptedSuffix,
+	}
+}
+
+// Encrypts reports whether sops encrypts the value reached through keys, applying
+// the rules the same way sops does: a value is affected when any key on its path matches.
+func (r Rules) Encrypts(keys []string) (bool, error) {
+	switch {
+	case r.EncryptedRegex != "":
+		re, err := regexp.Compile(r.EncryptedRegex)
+		if err != nil {
+			return false, fmt.Errorf("invalid encrypted_regex: %w", err)
+		}
+		return anyKey(keys, re.MatchString), nil
+	case r.UnencryptedRegex != "":
+		re, err := regexp.Compile(r.UnencryptedRegex)
+		if err != nil {
+			return false, fmt.Errorf("invalid unencrypted_regex: %w", err)
+		}
+		return !anyKey(keys, re.MatchString), nil
+	case r.EncryptedSuffix != "":
+		return anyKey(keys, func(k string) bool { return strings.HasSuffix(k, r.EncryptedSuffix) }), nil
+	}
+
+	suffix := r.UnencryptedSuffix
+	if suffix == "" {
+		suffix = DefaultUnencryptedSuffix
+	}
+	return !anyKey(keys, func(k string) bool { return strings.HasSuffix(k, suffix) }), nil
+}
+
+// PlaintextValues returns the paths of values the rules say are encrypted but that are
+// stored in plaintext, as left behind by a file decrypted in place. Empty values are
+// skipped, since sops does not encrypt them.
+func PlaintextValues(tree Branch, rules Rules) ([]Path, error) {
+	var paths []Path
+	var walkErr error
+	Walk(tree, func(path Path, value any) {
+		if walkErr != nil || value == nil || value == "" {
+			return
+		}
+		if s, ok := value.(string); ok && IsEncryptedValue(s) {
+			return
+		}
+		encrypts, err := rules.Encrypts(path.Keys())
+		if err != nil {
+			walkErr = err
+			return
+		}
+		if encrypts {
+			paths = append(paths, path)
+		}
+	})
+	return paths, walkErr
+}
+
+func anyKey(keys []string, match func(string) bool) bool {
+	for _, k := range keys {
+		if match(k) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package sopsfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRulesEncrypts(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   Rules
+		keys    []string
+		want    bool
+		wantErr bool
+	}{
+		{name: "default encrypts", keys: []string{"db", "password"}, want: true},
+		{name: "default suffix", keys: []string{"db", "port_unencrypted"}, want: false},
+		{name: "default suffix on parent", keys: []string{"public_unencrypted", "host"}, want: false},
+		{name: "custom unencrypted suffix", rules: Rules{UnencryptedSuffix: "_plain"}, keys: []string{"port_plain"}, want: false},
+		{name: "custom suffix replaces default", rules: Rules{UnencryptedSuffix: "_plain"}, keys: []string{"port_unencrypted"}, want: true},
+		{name: "encrypted suffix match", rules: Rules{EncryptedSuffix: "_secret"}, keys: []string{"api_secret"}, want: true},
+		{name: "encrypted suffix miss", rules: Rules{EncryptedSuffix: "_secret"}, keys: []string{"api_url"}, want: false},
+		{name: "encrypted regex match", rules: Rules{EncryptedRegex: "^(data|stringData)$"}, keys: []string{"data", "token"}, want: true},
+		{name: "encrypted regex miss", rules: Rules{EncryptedRegex: "^(data|stringData)$"}, keys: []string{"metadata", "name"}, want: false},
+		{name: "encrypted regex wins over suffix", rules: Rules{EncryptedRegex: "^pass", UnencryptedSuffix: "_plain"}, keys: []string{"pass_plain"}, want: true},
+		{name: "unencrypted regex match", rules: Rules{UnencryptedRegex: "^public"}, keys: []string{"public_key"}, want: false},
+		{name: "unencrypted regex miss", rules: Rules{UnencryptedRegex: "^public"}, keys: []string{"private_key"}, want: true},
+		{name: "list indexes are not keys", keys: nil, want: true},
+		{name: "invalid encrypted regex", rules: Rules{EncryptedRegex: "("}, keys: []string{"a"}, wantErr: true},
+		{name: "invalid unencrypted regex", rules: Rules{UnencryptedRegex: "("}, keys: []string{"a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.rules.Encrypts(tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Encrypts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Encrypts(%v) = %v, want %v", tt.keys, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaintextValues(t *testing.T) {
+	tests := []struct {
+		file string
+		want []string
+	}{
+		// db.port_unencrypted is left in plaintext by the default suffix
+		{file: "secrets.yaml", want: nil},
+		// mac_only_encrypted does not change which values are encrypted: replicas matches
+		// encrypted_regex but is stored in plaintext, public does not match
+		{file: "secrets.json", want: []string{"replicas"}},
+		{file: "secrets.env", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := Read(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			paths, err := PlaintextValues(f.Tree, f.Metadata.Rules())
+			if err != nil {
+				t.Fatalf("PlaintextValues() error = %v", err)
+			}
+			var got []string
+			for _, p := range paths {
+				got = append(got, p.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PlaintextValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaintextValuesDecrypted(t *testing.T) {
+	tree := Branch{
+		{Key: "password", Value: "hunter2"},
+		{Key: "empty", Value: ""},
+		{Key: "missing", Value: nil},
+		{Key: "port_unencrypted", Value: 5432},
+		{Key: "users", Value: []any{Branch{{Key: "token", Value: "ENC[AES256_GCM,data:x,type:str]"}}, "plain"}},
+	}
+	paths, err := PlaintextValues(tree, Rules{})
+	if err != nil {
+		t.Fatalf("PlaintextValues() error = %v", err)
+	}
+	want := []Path{{"password"}, {"users", 1}}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("PlaintextValues() = %v, want %v", paths, want)
+	}
+}
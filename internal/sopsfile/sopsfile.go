@@ -0,0 +1,180 @@
+// Package sopsfile reads the metadata of SOPS-encrypted files without decrypting them.
+package sopsfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrNotEncrypted is returned when a file has no sops metadata.
+var ErrNotEncrypted = errors.New("file is not encrypted with sops")
+
+// Format is the storage format of a SOPS file.
+type Format string
+
+// Supported formats.
+const (
+	FormatYAML   Format = "yaml"
+	FormatJSON   Format = "json"
+	FormatDotenv Format = "dotenv"
+	FormatINI    Format = "ini"
+	FormatBinary Format = "binary"
+)
+
+// FormatFromPath guesses the format from the file extension, the same way sops does.
+func FormatFromPath(path string) Format {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML
+	case ".json":
+		return FormatJSON
+	case ".env":
+		return FormatDotenv
+	case ".ini":
+		return FormatINI
+	default:
+		return FormatBinary
+	}
+}
+
+// File is a parsed SOPS file.
+type File struct {
+	Format   Format
+	Metadata *Metadata
+	// Tree is the document without the sops metadata. Keys are stored in plaintext by sops;
+	// values are ENC[...] strings, or plaintext where encryption was skipped.
+	Tree Branch
+}
+
+// Read parses the SOPS file at path.
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	f, err := Parse(data, FormatFromPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse parses SOPS file contents in the given format.
+// It returns ErrNotEncrypted when the document has no sops metadata.
+func Parse(data []byte, format Format) (*File, error) {
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		if format == FormatBinary {
+			return nil, ErrNotEncrypted
+		}
+		return nil, err
+	}
+
+	raw, _ := doc.Get("sops")
+	meta, ok := toPlain(raw).(map[string]any)
+	if !ok || len(meta) == 0 {
+		return nil, ErrNotEncrypted
+	}
+
+	return &File{
+		Format:   format,
+		Metadata: newMetadata(meta),
+		Tree:     doc.Without("sops"),
+	}, nil
+}
+
+// ReadMetadata reads the sops metadata of the file at path.
+func ReadMetadata(path string) (*Metadata, error) {
+	f, err := Read(path)
+	if err != nil {
+		return nil, err
+	}
+	return f.Metadata, nil
+}
+
+// ParseTree parses any document, encrypted or not, into a Branch without the sops metadata.
+// It is used for the plaintext output of sops.
+func ParseTree(data []byte, format Format) (Branch, error) {
+	doc, err := parseDocument(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Without("sops"), nil
+}
+
+// flatSeparator matches the separators sops uses to flatten metadata for dotenv and INI files,
+// e.g. "age__list_0__map_recipient".
+var flatSeparator = regexp.MustCompile(`__(list|map)_`)
+
+// flatNode is an intermediate tree node used while unflattening keys.
+type flatNode struct {
+	list     bool
+	value    *string
+	children map[string]*flatNode
+}
+
+func (n *flatNode) child(key string) *flatNode {
+	if n.children == nil {
+		n.children = make(map[string]*flatNode)
+	}
+	c, ok := n.children[key]
+	if !ok {
+		c = &flatNode{}
+		n.children[key] = c
+	}
+	return c
+}
+
+// unflatten rebuilds the metadata tree from flattened keys.
+func unflatten(entries map[string]string) map[string]any {
+	root := &flatNode{}
+	for key, value := range entries {
+		node := root
+		rest := key
+		for {
+			loc := flatSeparator.FindStringSubmatchIndex(rest)
+			if loc == nil {
+				break
+			}
+			node = node.child(rest[:loc[0]])
+			node.list = rest[loc[2]:loc[3]] == "list"
+			rest = rest[loc[1]:]
+		}
+		v := value
+		node.child(rest).value = &v
+	}
+
+	tree, _ := root.build().(map[string]any)
+	return tree
+}
+
+func (n *flatNode) build() any {
+	if n.value != nil {
+		return *n.value
+	}
+	if n.list {
+		indexes := make([]int, 0, len(n.children))
+		for k := range n.children {
+			if i, err := strconv.Atoi(k); err == nil && i >= 0 {
+				indexes = append(indexes, i)
+			}
+		}
+		sort.Ints(indexes)
+		list := make([]any, 0, len(indexes))
+		for _, i := range indexes {
+			list = append(list, n.children[strconv.Itoa(i)].build())
+		}
+		return list
+	}
+	m := make(map[string]any, len(n.children))
+	for k, c := range n.children {
+		m[k] = c.build()
+	}
+	return m
+}
@@ -0,0 +1,233 @@
+package sopsfile
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+const (
+	ageAlice = "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p"
+	ageBob   = "age1lggyhqrw2nlhcxprm67z43rta597azn8gknawjehu9d9dl0jq3yqqvfafg"
+)
+
+func TestFormatFromPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want Format
+	}{
+		{"secrets.yaml", FormatYAML},
+		{"deploy/secrets.YML", FormatYAML},
+		{"secrets.json", FormatJSON},
+		{".env", FormatDotenv},
+		{"app.env", FormatDotenv},
+		{"config.ini", FormatINI},
+		{"cert.pem", FormatBinary},
+		{"noext", FormatBinary},
+	}
+	for _, tt := range tests {
+		if got := FormatFromPath(tt.path); got != tt.want {
+			t.Errorf("FormatFromPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	modified := time.Date(2024, 3, 1, 10, 20, 30, 0, time.UTC)
+
+	tests := []struct {
+		file       string
+		format     Format
+		keys       []string
+		recipients map[string][]string
+		version    string
+	}{
+		{
+			file:   "secrets.yaml",
+			format: FormatYAML,
+			keys:   []string{"db", "tokens"},
+			recipients: map[string][]string{
+				BackendAge: {ageAlice, ageBob},
+				BackendPGP: {"85D77543B3D624B63CEA9E6DBC17301B491B3F21"},
+			},
+			version: "3.8.1",
+		},
+		{
+			file:   "secrets.json",
+			format: FormatJSON,
+			keys:   []string{"api_key", "replicas", "public"},
+			recipients: map[string][]string{
+				BackendAge: {ageAlice, ageBob},
+				BackendKMS: {"arn:aws:kms:eu-west-1:111122223333:key/abcd"},
+			},
+			version: "3.9.0",
+		},
+		{
+			file:   "secrets.env",
+			format: FormatDotenv,
+			keys:   []string{"DATABASE_URL", "API_TOKEN"},
+			recipients: map[string][]string{
+				BackendAge:     {ageAlice, ageBob},
+				BackendHCVault: {"https://vault.example.com/v1/sops/keys/app"},
+			},
+			version: "3.8.1",
+		},
+		{
+			file:   "secrets.ini",
+			format: FormatINI,
+			keys:   []string{"database"},
+			recipients: map[string][]string{
+				BackendAzureKV: {"https://vault.vault.azure.net/keys/sops-key/0123"},
+				BackendGCPKMS:  {"projects/p/locations/global/keyRings/r/cryptoKeys/k"},
+			},
+			version: "3.8.1",
+		},
+		{
+			file:       "secrets.bin",
+			format:     FormatBinary,
+			keys:       []string{"data"},
+			recipients: map[string][]string{BackendAge: {ageAlice}},
+			version:    "3.8.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			f, err := Read(filepath.Join("testdata", tt.file))
+			if err != nil {
+				t.Fatalf("Read() error = %v", err)
+			}
+			if f.Format != tt.format {
+				t.Errorf("Format = %q, want %q", f.Format, tt.format)
+			}
+
+			var keys []string
+			for _, item := range f.Tree {
+				keys = append(keys, item.Key)
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("Tree keys = %v, want %v", keys, tt.keys)
+			}
+
+			if got := f.Metadata.Recipients(); !reflect.DeepEqual(got, tt.recipients) {
+				t.Errorf("Recipients() = %v, want %v", got, tt.recipients)
+			}
+			if f.Metadata.Version != tt.version {
+				t.Errorf("Version = %q, want %q", f.Metadata.Version, tt.version)
+			}
+			if !f.Metadata.LastModified.Equal(modified) {
+				t.Errorf("LastModified = %v, want %v", f.Metadata.LastModified, modified)
+			}
+		})
+	}
+}
+
+func TestReadNotEncrypted(t *testing.T) {
+	_, err := Read(filepath.Join("testdata", "plain.yaml"))
+	if !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Read() error = %v, want ErrNotEncrypted", err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		format  Format
+		wantErr error
+	}{
+		{name: "empty yaml", data: "", format: FormatYAML, wantErr: ErrNotEncrypted},
+		{name: "empty sops block", data: "a: b\nsops: {}\n", format: FormatYAML, wantErr: ErrNotEncrypted},
+		{name: "plain dotenv", data: "A=1\n", format: FormatDotenv, wantErr: ErrNotEncrypted},
+		{name: "plain binary", data: "not json", format: FormatBinary, wantErr: ErrNotEncrypted},
+		{name: "yaml list", data: "- a\n", format: FormatYAML},
+		{name: "json trailing data", data: `{"sops": {"version": "3"}} {}`, format: FormatJSON},
+		{name: "unknown format", data: "a", format: Format("toml")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format)
+			if err == nil {
+				t.Fatal("Parse() error = nil")
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseTree(t *testing.T) {
+	tree, err := ParseTree([]byte("a: 1\nsops:\n    version: 3\nb: [x, y]\n"), FormatYAML)
+	if err != nil {
+		t.Fatalf("ParseTree() error = %v", err)
+	}
+	want := Branch{{Key: "a", Value: 1}, {Key: "b", Value: []any{"x", "y"}}}
+	if !reflect.DeepEqual(tree, want) {
+		t.Errorf("ParseTree() = %#v, want %#v", tree, want)
+	}
+}
+
+func TestUnflatten(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries map[string]string
+		want    map[string]any
+	}{
+		{
+			name:    "scalar",
+			entries: map[string]string{"version": "3.8.1"},
+			want:    map[string]any{"version": "3.8.1"},
+		},
+		{
+			name: "list of maps",
+			entries: map[string]string{
+				"age__list_0__map_recipient": "a",
+				"age__list_1__map_recipient": "b",
+				"age__list_1__map_enc":       "x",
+			},
+			want: map[string]any{"age": []any{
+				map[string]any{"recipient": "a"},
+				map[string]any{"recipient": "b", "enc": "x"},
+			}},
+		},
+		{
+			name: "list indexes sort numerically",
+			entries: map[string]string{
+				"age__list_10__map_recipient": "k",
+				"age__list_2__map_recipient":  "c",
+			},
+			want: map[string]any{"age": []any{
+				map[string]any{"recipient": "c"},
+				map[string]any{"recipient": "k"},
+			}},
+		},
+		{
+			name: "nested key groups",
+			entries: map[string]string{
+				"key_groups__list_0__map_age__list_0__map_recipient": "a",
+				"key_groups__list_1__map_pgp__list_0__map_fp":        "F",
+			},
+			want: map[string]any{"key_groups": []any{
+				map[string]any{"age": []any{map[string]any{"recipient": "a"}}},
+				map[string]any{"pgp": []any{map[string]any{"fp": "F"}}},
+			}},
+		},
+		{
+			name:    "map of scalars",
+			entries: map[string]string{"kms__list_0__map_context__map_env": "prod"},
+			want: map[string]any{"kms": []any{
+				map[string]any{"context": map[string]any{"env": "prod"}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unflatten(tt.entries); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("unflatten() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
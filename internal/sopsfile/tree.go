@@ -0,0 +1,288 @@
+package sopsfile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Item is a key/value pair of a Branch.
+type Item struct {
+	Key   string
+	Value any
+}
+
+// Branch is an ordered map, mirroring the tree model sops itself uses.
+// Values are Branch, []any or scalars (string, bool, numbers, nil).
+type Branch []Item
+
+// Get returns the value stored under key.
+func (b Branch) Get(key string) (any, bool) {
+	for _, item := range b {
+		if item.Key == key {
+			return item.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Without returns a copy of the branch without key.
+func (b Branch) Without(key string) Branch {
+	out := make(Branch, 0, len(b))
+	for _, item := range b {
+		if item.Key != key {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// Plain returns the branch as nested map[string]any and []any values.
+func (b Branch) Plain() map[string]any {
+	m, _ := toPlain(b).(map[string]any)
+	return m
+}
+
+// IsEncryptedValue returns true if s is a sops-encrypted value ("ENC[AES256_GCM,...]").
+func IsEncryptedValue(s string) bool {
+	return strings.HasPrefix(s, "ENC[") && strings.HasSuffix(s, "]")
+}
+
+// parseDocument parses a whole document, including any "sops" key, into a Branch.
+func parseDocument(data []byte, format Format) (Branch, error) {
+	switch format {
+	case FormatYAML:
+		return parseYAML(data)
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatBinary:
+		tree, err := parseJSON(data)
+		if err != nil {
+			// Plaintext binary files are stored by sops under a single "data" key
+			return Branch{{Key: "data", Value: string(data)}}, nil
+		}
+		return tree, nil
+	case FormatDotenv:
+		return parseDotenv(data), nil
+	case FormatINI:
+		return parseINI(data), nil
+	}
+	return nil, fmt.Errorf("unsupported format: %s", format)
+}
+
+func parseYAML(data []byte) (Branch, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	if doc.Kind == 0 {
+		return Branch{}, nil
+	}
+	v, err := yamlValue(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse yaml: %w", err)
+	}
+	tree, ok := v.(Branch)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse yaml: top level is not a mapping")
+	}
+	return tree, nil
+}
+
+func yamlValue(node *yaml.Node) (any, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return Branch{}, nil
+		}
+		return yamlValue(node.Content[0])
+	case yaml.AliasNode:
+		return yamlValue(node.Alias)
+	case yaml.MappingNode:
+		branch := make(Branch, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := yamlValue(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			branch = append(branch, Item{Key: node.Content[i].Value, Value: v})
+		}
+		return branch, nil
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(node.Content))
+		for _, c := range node.Content {
+			v, err := yamlValue(c)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	default:
+		var v any
+		if err := node.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+func parseJSON(data []byte) (Branch, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := jsonValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse json: %w", err)
+	}
+	tree, ok := v.(Branch)
+	if !ok {
+		return nil, fmt.Errorf("failed to parse json: top level is not an object")
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to parse json: trailing data")
+	}
+	return tree, nil
+}
+
+func jsonValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		branch := Branch{}
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := keyTok.(string)
+			v, err := jsonValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			branch = append(branch, Item{Key: key, Value: v})
+		}
+		_, err := dec.Token()
+		return branch, err
+	case '[':
+		list := []any{}
+		for dec.More() {
+			v, err := jsonValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+	return nil, fmt.Errorf("unexpected delimiter %s", delim)
+}
+
+// parseDotenv parses KEY=value lines. Flattened sops metadata is kept under a "sops" branch.
+func parseDotenv(data []byte) Branch {
+	tree := Branch{}
+	meta := make(map[string]string)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if rest, isMeta := strings.CutPrefix(key, "sops_"); isMeta {
+			meta[rest] = value
+			continue
+		}
+		tree = append(tree, Item{Key: key, Value: value})
+	}
+
+	if len(meta) > 0 {
+		tree = append(tree, Item{Key: "sops", Value: unflatten(meta)})
+	}
+	return tree
+}
+
+// parseINI parses sections into branches. Flattened sops metadata from the [sops]
+// section is kept under a "sops" branch.
+func parseINI(data []byte) Branch {
+	tree := Branch{}
+	section := -1
+	meta := make(map[string]string)
+	inMeta := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+			continue
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			inMeta = name == "sops"
+			if !inMeta {
+				tree = append(tree, Item{Key: name, Value: Branch{}})
+				section = len(tree) - 1
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch {
+		case inMeta:
+			meta[key] = value
+		case section < 0:
+			tree = append(tree, Item{Key: key, Value: value})
+		default:
+			branch, _ := tree[section].Value.(Branch)
+			tree[section].Value = append(branch, Item{Key: key, Value: value})
+		}
+	}
+
+	if len(meta) > 0 {
+		tree = append(tree, Item{Key: "sops", Value: unflatten(meta)})
+	}
+	return tree
+}
+
+// toPlain converts Branch values into map[string]any, recursively.
+func toPlain(v any) any {
+	switch t := v.(type) {
+	case Branch:
+		m := make(map[string]any, len(t))
+		for _, item := range t {
+			m[item.Key] = toPlain(item.Value)
+		}
+		return m
+	case []any:
+		list := make([]any, len(t))
+		for i, item := range t {
+			list[i] = toPlain(item)
+		}
+		return list
+	}
+	return v
+}
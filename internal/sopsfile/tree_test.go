@@ -0,0 +1,140 @@
+package sopsfile
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseDocument(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		want   Branch
+	}{
+		{
+			name:   "yaml keeps key order",
+			data:   "b: 1\na:\n    - x\n    - c: true\n",
+			format: FormatYAML,
+			want: Branch{
+				{Key: "b", Value: 1},
+				{Key: "a", Value: []any{"x", Branch{{Key: "c", Value: true}}}},
+			},
+		},
+		{
+			name:   "yaml aliases",
+			data:   "base: &b {x: 1}\ncopy: *b\n",
+			format: FormatYAML,
+			want: Branch{
+				{Key: "base", Value: Branch{{Key: "x", Value: 1}}},
+				{Key: "copy", Value: Branch{{Key: "x", Value: 1}}},
+			},
+		},
+		{
+			name:   "json keeps key order and numbers",
+			data:   `{"b": 1.50, "a": [null, {"c": "d"}]}`,
+			format: FormatJSON,
+			want: Branch{
+				{Key: "b", Value: json.Number("1.50")},
+				{Key: "a", Value: []any{nil, Branch{{Key: "c", Value: "d"}}}},
+			},
+		},
+		{
+			name:   "dotenv",
+			data:   "# comment\n\nA=1\nB=x=y\ninvalid\n",
+			format: FormatDotenv,
+			want:   Branch{{Key: "A", Value: "1"}, {Key: "B", Value: "x=y"}},
+		},
+		{
+			name:   "dotenv metadata",
+			data:   "A=1\nsops_version=3.8.1\n",
+			format: FormatDotenv,
+			want:   Branch{{Key: "A", Value: "1"}, {Key: "sops", Value: map[string]any{"version": "3.8.1"}}},
+		},
+		{
+			name:   "ini",
+			data:   "top = 1\n; comment\n[s]\nk = v\n[sops]\nversion = 3.8.1\n",
+			format: FormatINI,
+			want: Branch{
+				{Key: "top", Value: "1"},
+				{Key: "s", Value: Branch{{Key: "k", Value: "v"}}},
+				{Key: "sops", Value: map[string]any{"version": "3.8.1"}},
+			},
+		},
+		{
+			name:   "plaintext binary",
+			data:   "raw",
+			format: FormatBinary,
+			want:   Branch{{Key: "data", Value: "raw"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDocument([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("parseDocument() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDocument() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBranch(t *testing.T) {
+	b := Branch{
+		{Key: "a", Value: "1"},
+		{Key: "sops", Value: Branch{{Key: "version", Value: "3"}}},
+		{Key: "l", Value: []any{Branch{{Key: "x", Value: 1}}}},
+	}
+
+	if v, ok := b.Get("a"); !ok || v != "1" {
+		t.Errorf(`Get("a") = %v, %v`, v, ok)
+	}
+	if _, ok := b.Get("missing"); ok {
+		t.Error(`Get("missing") found a value`)
+	}
+
+	without := b.Without("sops")
+	if want := (Branch{b[0], b[2]}); !reflect.DeepEqual(without, want) {
+		t.Errorf(`Without("sops") = %#v, want %#v`, without, want)
+	}
+	if len(b) != 3 {
+		t.Error("Without() modified the branch")
+	}
+}
+
+func TestIsEncryptedValue(t *testing.T) {
+	tests := []struct {
+		value string
+		want  bool
+	}{
+		{"ENC[AES256_GCM,data:Ym9i,iv:MTIz,tag:YWJj,type:str]", true},
+		{"ENC[]", true},
+		{"ENC[AES256_GCM", false},
+		{"plain", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := IsEncryptedValue(tt.value); got != tt.want {
+			t.Errorf("IsEncryptedValue(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestBranchPlain(t *testing.T) {
+	b := Branch{
+		{Key: "a", Value: "1"},
+		{Key: "db", Value: Branch{{Key: "user", Value: "bob"}}},
+		{Key: "l", Value: []any{Branch{{Key: "x", Value: 1}}, "y"}},
+	}
+	want := map[string]any{
+		"a":  "1",
+		"db": map[string]any{"user": "bob"},
+		"l":  []any{map[string]any{"x": 1}, "y"},
+	}
+	if got := b.Plain(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Plain() = %#v, want %#v", got, want)
+	}
+}